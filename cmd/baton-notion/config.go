@@ -6,15 +6,30 @@ import (
 )
 
 const (
-	apiKeyFlag    = "api-key"
-	scimTokenFlag = "scim-token"
+	apiKeyFlag                   = "api-key"
+	scimTokenFlag                = "scim-token"
+	databaseIDsFlag              = "database-ids"
+	databasePeoplePropertiesFlag = "database-people-properties"
+	ticketDatabaseIDFlag         = "ticket-database-id"
+	discoverGuestsFlag           = "discover-guests"
+	publicPagesFlag              = "public-pages"
+	internalEmailDomainsFlag     = "internal-email-domains"
+	includeEmailDomainsFlag      = "include-email-domains"
+	excludeEmailDomainsFlag      = "exclude-email-domains"
+	includeGroupsFlag            = "include-groups"
+	excludeGroupsFlag            = "exclude-groups"
+	skipBotsFlag                 = "skip-bots"
+	groupFetchConcurrencyFlag    = "group-fetch-concurrency"
+	recordDirFlag                = "record-dir"
+	replayDirFlag                = "replay-dir"
+	stateDirFlag                 = "state-dir"
 )
 
 var (
 	APIKeyField = field.StringField(
 		apiKeyFlag,
-		field.WithRequired(true),
-		field.WithDescription("The Notion API key used to connect to the Notion API. ($BATON_API_KEY)"),
+		field.WithRequired(false),
+		field.WithDescription("The Notion API key used to connect to the Notion API. Optional if a SCIM token is given. ($BATON_API_KEY)"),
 	)
 
 	SCIMTokenField = field.StringField(
@@ -23,7 +38,110 @@ var (
 		field.WithDescription("The Notion SCIM token used to connect to the Notion SCIM API. ($BATON_SCIM_TOKEN)"),
 	)
 
-	ConfigurationFields = []field.SchemaField{APIKeyField, SCIMTokenField}
+	DatabaseIDsField = field.StringSliceField(
+		databaseIDsFlag,
+		field.WithDescription("IDs of Notion databases whose rows are synced as resources. ($BATON_DATABASE_IDS)"),
+	)
+
+	DatabasePeoplePropertiesField = field.StringSliceField(
+		databasePeoplePropertiesFlag,
+		field.WithDescription("Names of the People properties that grant access to a database row, e.g. Owner. ($BATON_DATABASE_PEOPLE_PROPERTIES)"),
+	)
+
+	TicketDatabaseIDField = field.StringField(
+		ticketDatabaseIDFlag,
+		field.WithDescription("The ID of the Notion database in which tickets are created. ($BATON_TICKET_DATABASE_ID)"),
+	)
+
+	DiscoverGuestsField = field.BoolField(
+		discoverGuestsFlag,
+		field.WithDescription("Crawl the content shared with the integration for guests and sync them as users. ($BATON_DISCOVER_GUESTS)"),
+	)
+
+	PublicPagesField = field.BoolField(
+		publicPagesFlag,
+		field.WithDescription("Sync the pages shared with the integration that are published to the web. ($BATON_PUBLIC_PAGES)"),
+	)
+
+	InternalEmailDomainsField = field.StringSliceField(
+		internalEmailDomainsFlag,
+		field.WithDescription("Email domains of internal users, e.g. example.com. Users with other email addresses are classified as external. ($BATON_INTERNAL_EMAIL_DOMAINS)"),
+	)
+
+	IncludeEmailDomainsField = field.StringSliceField(
+		includeEmailDomainsFlag,
+		field.WithDescription("Only sync people with an email address in these domains. ($BATON_INCLUDE_EMAIL_DOMAINS)"),
+	)
+
+	ExcludeEmailDomainsField = field.StringSliceField(
+		excludeEmailDomainsFlag,
+		field.WithDescription("Don't sync people with an email address in these domains. ($BATON_EXCLUDE_EMAIL_DOMAINS)"),
+	)
+
+	IncludeGroupsField = field.StringSliceField(
+		includeGroupsFlag,
+		field.WithDescription("Only sync groups whose name matches one of these regular expressions. ($BATON_INCLUDE_GROUPS)"),
+	)
+
+	ExcludeGroupsField = field.StringSliceField(
+		excludeGroupsFlag,
+		field.WithDescription("Don't sync groups whose name matches one of these regular expressions. ($BATON_EXCLUDE_GROUPS)"),
+	)
+
+	SkipBotsField = field.BoolField(
+		skipBotsFlag,
+		field.WithDescription("Don't sync bot users. ($BATON_SKIP_BOTS)"),
+	)
+
+	GroupFetchConcurrencyField = field.IntField(
+		groupFetchConcurrencyFlag,
+		field.WithDescription("Number of workers fetching group details ahead of syncing group members, 0 to fetch them one at a time. ($BATON_GROUP_FETCH_CONCURRENCY)"),
+	)
+
+	RecordDirField = field.StringField(
+		recordDirFlag,
+		field.WithDescription("Write every Notion request and response to this directory, with tokens and email addresses redacted. ($BATON_RECORD_DIR)"),
+	)
+
+	ReplayDirField = field.StringField(
+		replayDirFlag,
+		field.WithDescription("Answer Notion requests from the recordings in this directory instead of sending them. ($BATON_REPLAY_DIR)"),
+	)
+
+	StateDirField = field.StringField(
+		stateDirFlag,
+		field.WithDescription("Keep the SCIM groups in this directory between syncs, and request only those modified since the last one. ($BATON_STATE_DIR)"),
+	)
+
+	ConfigurationFields = []field.SchemaField{
+		APIKeyField,
+		SCIMTokenField,
+		DatabaseIDsField,
+		DatabasePeoplePropertiesField,
+		TicketDatabaseIDField,
+		DiscoverGuestsField,
+		PublicPagesField,
+		InternalEmailDomainsField,
+		IncludeEmailDomainsField,
+		ExcludeEmailDomainsField,
+		IncludeGroupsField,
+		ExcludeGroupsField,
+		SkipBotsField,
+		GroupFetchConcurrencyField,
+		RecordDirField,
+		ReplayDirField,
+		StateDirField,
+		field.TicketingField,
+	}
+
+	FieldRelationships = []field.SchemaFieldRelationship{
+		field.FieldsAtLeastOneUsed(APIKeyField, SCIMTokenField),
+		field.FieldsDependentOn([]field.SchemaField{DatabaseIDsField, TicketDatabaseIDField, DiscoverGuestsField, PublicPagesField}, []field.SchemaField{APIKeyField}),
+		field.FieldsRequiredTogether(DatabaseIDsField, DatabasePeoplePropertiesField),
+		field.FieldsDependentOn([]field.SchemaField{field.TicketingField}, []field.SchemaField{TicketDatabaseIDField}),
+		field.FieldsMutuallyExclusive(RecordDirField, ReplayDirField),
+		field.FieldsDependentOn([]field.SchemaField{StateDirField}, []field.SchemaField{SCIMTokenField}),
+	}
 )
 
 // ValidateConfig is run after the configuration is loaded, and should return an
@@ -25,7 +25,8 @@ func main() {
 		"baton-notion",
 		getConnector,
 		field.Configuration{
-			Fields: ConfigurationFields,
+			Fields:      ConfigurationFields,
+			Constraints: FieldRelationships,
 		},
 	)
 	if err != nil {
@@ -48,16 +49,37 @@ func getConnector(ctx context.Context, v *viper.Viper) (types.ConnectorServer, e
 		return nil, err
 	}
 
-	apiKey := v.GetString(apiKeyFlag)
-	scimToken := v.GetString(scimTokenFlag)
-
-	cb, err := connector.New(ctx, apiKey, scimToken)
+	cb, err := connector.New(ctx,
+		connector.WithAPIKey(v.GetString(apiKeyFlag)),
+		connector.WithSCIMToken(v.GetString(scimTokenFlag)),
+		connector.WithDatabases(v.GetStringSlice(databaseIDsFlag), v.GetStringSlice(databasePeoplePropertiesFlag)),
+		connector.WithTicketDatabase(v.GetString(ticketDatabaseIDFlag)),
+		connector.WithGuestDiscovery(v.GetBool(discoverGuestsFlag)),
+		connector.WithPublicPages(v.GetBool(publicPagesFlag)),
+		connector.WithInternalEmailDomains(v.GetStringSlice(internalEmailDomainsFlag)...),
+		connector.WithFilters(connector.Filters{
+			IncludeEmailDomains: v.GetStringSlice(includeEmailDomainsFlag),
+			ExcludeEmailDomains: v.GetStringSlice(excludeEmailDomainsFlag),
+			IncludeGroups:       v.GetStringSlice(includeGroupsFlag),
+			ExcludeGroups:       v.GetStringSlice(excludeGroupsFlag),
+			SkipBots:            v.GetBool(skipBotsFlag),
+		}),
+		connector.WithGroupFetchConcurrency(v.GetInt(groupFetchConcurrencyFlag)),
+		connector.WithRecording(v.GetString(recordDirFlag)),
+		connector.WithReplay(v.GetString(replayDirFlag)),
+		connector.WithStateDir(v.GetString(stateDirFlag)),
+	)
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
 	}
 
-	c, err := connectorbuilder.NewConnector(ctx, cb)
+	var opts []connectorbuilder.Opt
+	if v.GetBool(field.TicketingField.FieldName) {
+		opts = append(opts, connectorbuilder.WithTicketingEnabled())
+	}
+
+	c, err := connectorbuilder.NewConnector(ctx, cb, opts...)
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
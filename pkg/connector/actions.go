@@ -0,0 +1,202 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/google/uuid"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// actionHandler runs a custom action. A handler that fails part way can return
+// a response together with the error, in which case the invocation is reported
+// as failed with that response instead of as an error.
+type actionHandler func(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error)
+
+type action struct {
+	schema  *v2.BatonActionSchema
+	handler actionHandler
+}
+
+type actionResult struct {
+	name     string
+	status   v2.BatonActionStatus
+	response *structpb.Struct
+}
+
+// maxActionResults is the number of action results kept for GetActionStatus.
+// Older results are dropped first.
+const maxActionResults = 100
+
+// actionManager runs custom actions synchronously and remembers their results
+// so that GetActionStatus can report on them. A result is forgotten once
+// GetActionStatus returned it, or when maxActionResults newer ones are kept.
+type actionManager struct {
+	actions map[string]action
+
+	mu      sync.Mutex
+	results map[string]actionResult
+	// order holds the IDs of the kept results, oldest first.
+	order []string
+}
+
+func newActionManager() *actionManager {
+	return &actionManager{
+		actions: make(map[string]action),
+		results: make(map[string]actionResult),
+	}
+}
+
+func (m *actionManager) register(schema *v2.BatonActionSchema, handler actionHandler) {
+	m.actions[schema.GetName()] = action{
+		schema:  schema,
+		handler: handler,
+	}
+}
+
+func (m *actionManager) ListActionSchemas(_ context.Context) ([]*v2.BatonActionSchema, annotations.Annotations, error) {
+	rv := make([]*v2.BatonActionSchema, 0, len(m.actions))
+	for _, a := range m.actions {
+		rv = append(rv, a.schema)
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].GetName() < rv[j].GetName()
+	})
+
+	return rv, nil, nil
+}
+
+func (m *actionManager) GetActionSchema(_ context.Context, name string) (*v2.BatonActionSchema, annotations.Annotations, error) {
+	a, ok := m.actions[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("notion-connector: unknown action %s", name)
+	}
+
+	return a.schema, nil, nil
+}
+
+func (m *actionManager) InvokeAction(ctx context.Context, name string, args *structpb.Struct) (string, v2.BatonActionStatus, *structpb.Struct, annotations.Annotations, error) {
+	a, ok := m.actions[name]
+	if !ok {
+		return "", v2.BatonActionStatus_BATON_ACTION_STATUS_FAILED, nil, nil, fmt.Errorf("notion-connector: unknown action %s", name)
+	}
+
+	id := uuid.NewString()
+	status := v2.BatonActionStatus_BATON_ACTION_STATUS_COMPLETE
+	response, annos, err := a.handler(ctx, args)
+	if err != nil {
+		status = v2.BatonActionStatus_BATON_ACTION_STATUS_FAILED
+		if response != nil {
+			ctxzap.Extract(ctx).Warn("notion-connector: action failed", zap.String("action", name), zap.Error(err))
+			err = nil
+		}
+	}
+
+	m.mu.Lock()
+	m.results[id] = actionResult{
+		name:     name,
+		status:   status,
+		response: response,
+	}
+	m.order = append(m.order, id)
+	for len(m.results) > maxActionResults {
+		delete(m.results, m.order[0])
+		m.order = m.order[1:]
+	}
+	m.mu.Unlock()
+
+	return id, status, response, annos, err
+}
+
+func (m *actionManager) GetActionStatus(_ context.Context, id string) (v2.BatonActionStatus, string, *structpb.Struct, annotations.Annotations, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result, ok := m.results[id]
+	if !ok {
+		return v2.BatonActionStatus_BATON_ACTION_STATUS_UNKNOWN, "", nil, nil, fmt.Errorf("notion-connector: unknown action invocation %s", id)
+	}
+
+	// Actions run synchronously, so every result is final.
+	delete(m.results, id)
+	m.order = slices.DeleteFunc(m.order, func(o string) bool { return o == id })
+
+	return result.status, result.name, result.response, nil, nil
+}
+
+// RegisterActionManager returns the custom actions supported by the connector.
+func (nt *Notion) RegisterActionManager(_ context.Context) (connectorbuilder.CustomActionManager, error) {
+	m := newActionManager()
+	if nt.client != nil {
+		m.register(listUserContentActionSchema, nt.listUserContent)
+	}
+
+	if nt.scimClient != nil {
+		m.register(offboardUserActionSchema, nt.offboardUser)
+	}
+
+	if nt.ticketDatabaseID != "" {
+		m.register(addTicketCommentActionSchema, nt.addTicketComment)
+	}
+
+	return m, nil
+}
+
+func stringArgumentField(name, displayName, description string, required bool) *config.Field {
+	return &config.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Description: description,
+		IsRequired:  required,
+		Field:       &config.Field_StringField{StringField: &config.StringField{}},
+	}
+}
+
+// stringListReturnField describes a return value that is a list of strings.
+func stringListReturnField(name, displayName, description string) *config.Field {
+	return &config.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Description: description,
+		Field:       &config.Field_StringSliceField{StringSliceField: &config.StringSliceField{}},
+	}
+}
+
+// jsonStrings encodes each item of a list return value as a JSON object, so
+// that the list matches a field from stringListReturnField.
+func jsonStrings(items []map[string]interface{}) ([]interface{}, error) {
+	rv := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, string(b))
+	}
+	return rv, nil
+}
+
+// stringArgument returns the value of a required string argument.
+func stringArgument(args *structpb.Struct, name string) (string, error) {
+	value, ok := args.GetFields()[name]
+	if !ok {
+		return "", fmt.Errorf("notion-connector: missing argument %s", name)
+	}
+
+	s, ok := value.GetKind().(*structpb.Value_StringValue)
+	if !ok || s.StringValue == "" {
+		return "", fmt.Errorf("notion-connector: argument %s must be a non-empty string", name)
+	}
+
+	return s.StringValue, nil
+}
@@ -0,0 +1,67 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newTestActionManager() *actionManager {
+	m := newActionManager()
+	m.register(&v2.BatonActionSchema{Name: "noop"}, func(context.Context, *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+		return &structpb.Struct{}, nil, nil
+	})
+	return m
+}
+
+func TestActionResultIsForgottenOnceReported(t *testing.T) {
+	ctx := context.Background()
+	m := newTestActionManager()
+
+	id, _, _, _, err := m.InvokeAction(ctx, "noop", &structpb.Struct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, name, _, _, err := m.GetActionStatus(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != v2.BatonActionStatus_BATON_ACTION_STATUS_COMPLETE || name != "noop" {
+		t.Errorf("got status %s of action %q, want complete noop", status, name)
+	}
+
+	if _, _, _, _, err := m.GetActionStatus(ctx, id); err == nil {
+		t.Error("got the status of a result that was already reported")
+	}
+	if len(m.results) != 0 || len(m.order) != 0 {
+		t.Errorf("got %d results and %d IDs kept, want none", len(m.results), len(m.order))
+	}
+}
+
+func TestActionResultsAreCapped(t *testing.T) {
+	ctx := context.Background()
+	m := newTestActionManager()
+
+	var ids []string
+	for i := 0; i < maxActionResults+5; i++ {
+		id, _, _, _, err := m.InvokeAction(ctx, "noop", &structpb.Struct{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if len(m.results) != maxActionResults || len(m.order) != maxActionResults {
+		t.Fatalf("got %d results and %d IDs kept, want %d", len(m.results), len(m.order), maxActionResults)
+	}
+	if _, _, _, _, err := m.GetActionStatus(ctx, ids[0]); err == nil {
+		t.Error("got the status of the oldest result, want it dropped")
+	}
+	if _, _, _, _, err := m.GetActionStatus(ctx, ids[len(ids)-1]); err != nil {
+		t.Errorf("got error %v for the newest result", err)
+	}
+}
@@ -0,0 +1,234 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/dstotijn/go-notion"
+)
+
+// The size of the generated workspace the benchmarks run against.
+const (
+	benchUsers           = 50000
+	benchGroups          = 5000
+	benchMembersPerGroup = 10
+
+	// benchSCIMOnlyUsers is the number of users known to SCIM only, which
+	// the user syncer looks up one by one.
+	benchSCIMOnlyUsers = 100
+)
+
+// newBenchServer starts a fake Notion workspace of benchUsers users and
+// benchGroups groups, each with benchMembersPerGroup members.
+func newBenchServer(b *testing.B) *notiontest.Server {
+	b.Helper()
+
+	s := notiontest.NewServer()
+	b.Cleanup(s.Close)
+
+	users := make([]notion.User, 0, benchUsers+1)
+	scimUsers := make([]notionScim.User, 0, benchUsers+benchSCIMOnlyUsers)
+	for i := 0; i < benchUsers; i++ {
+		id := fmt.Sprintf("user-%05d", i)
+		email := fmt.Sprintf("user%05d@example.com", i)
+
+		users = append(users, notion.User{
+			BaseUser: notion.BaseUser{ID: id},
+			Type:     notion.UserTypePerson,
+			Name:     fmt.Sprintf("User %05d", i),
+			Person:   &notion.Person{Email: email},
+		})
+		scimUsers = append(scimUsers, notionScim.User{
+			ID:       id,
+			UserName: email,
+			Emails:   []notionScim.Email{{Value: email, Primary: true}},
+			Active:   true,
+		})
+	}
+	for i := 0; i < benchSCIMOnlyUsers; i++ {
+		email := fmt.Sprintf("scim%05d@example.com", i)
+		scimUsers = append(scimUsers, notionScim.User{
+			ID:       fmt.Sprintf("scim-user-%05d", i),
+			UserName: email,
+			Emails:   []notionScim.Email{{Value: email, Primary: true}},
+			Active:   true,
+		})
+	}
+	users = append(users, notion.User{
+		BaseUser: notion.BaseUser{ID: "user-bot"},
+		Type:     notion.UserTypeBot,
+		Name:     "Integration",
+		Bot:      &notion.Bot{},
+	})
+	s.AddUsers(users...)
+	s.AddSCIMUsers(scimUsers...)
+
+	groups := make([]notionScim.Group, 0, benchGroups)
+	for i := 0; i < benchGroups; i++ {
+		group := notionScim.Group{
+			ID:          fmt.Sprintf("group-%04d", i),
+			DisplayName: fmt.Sprintf("Group %04d", i),
+		}
+		for j := 0; j < benchMembersPerGroup; j++ {
+			member := (i*benchMembersPerGroup + j) % benchUsers
+			group.Members = append(group.Members, notionScim.Member{Value: fmt.Sprintf("user-%05d", member)})
+		}
+		groups = append(groups, group)
+	}
+	s.AddGroups(groups...)
+
+	return s
+}
+
+// benchClock is a Clock whose waits return at once and move it forward, so
+// that the benchmarks go through the rate limiter without sleeping and still
+// tell how long a sync would take at the Notion rate. A wait moves it to the
+// time last told plus the delay, or leaves it when it is already past that.
+type benchClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	told time.Time
+}
+
+func (c *benchClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.told = c.now
+	return c.now
+}
+
+func (c *benchClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	if at := c.told.Add(d); at.After(c.now) {
+		c.now = at
+	}
+	now := c.now
+	c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	ch <- now
+	return ch
+}
+
+// newBenchConnector returns a connector for s that rate limits requests at
+// the default rate of Notion, by clock.
+func newBenchConnector(ctx context.Context, b *testing.B, s *notiontest.Server, clock *benchClock) *Notion {
+	b.Helper()
+
+	nt, err := New(ctx,
+		WithAPIKey("api-key"),
+		WithSCIMToken("scim-token"),
+		WithHTTPClient(s.Client()),
+		WithClock(clock),
+		WithInternalEmailDomains("example.com"),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return nt
+}
+
+// reportSyncTime reports the time per operation the rate limiter made the
+// requests wait since start, which is how long they would take at the Notion
+// rate when Notion answers instantly.
+func reportSyncTime(b *testing.B, clock *benchClock, start time.Time) {
+	b.ReportMetric(clock.Now().Sub(start).Seconds()/float64(b.N), "rate-limited-s/op")
+}
+
+// reportRequests reports the number of requests the server received per
+// operation since the benchmark timer was reset, when it had received before
+// requests.
+func reportRequests(b *testing.B, s *notiontest.Server, before int) {
+	b.ReportMetric(float64(len(s.Requests())-before)/float64(b.N), "requests/op")
+}
+
+// BenchmarkUserList lists all users, through every page of every phase.
+// Allocations include those of the fake server, which runs in process.
+func BenchmarkUserList(b *testing.B) {
+	ctx := context.Background()
+	s := newBenchServer(b)
+	clock := &benchClock{}
+	nt := newBenchConnector(ctx, b, s, clock)
+	users := userBuilder(nt.client, nt.scimClient, nil, false, nil, nil, nt.pageSize)
+
+	b.ReportAllocs()
+	before := len(s.Requests())
+	start := clock.Now()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var count int
+		token := ""
+		for {
+			resources, next, _, err := users.List(ctx, nil, &pagination.Token{Token: token})
+			if err != nil {
+				b.Fatal(err)
+			}
+			count += len(resources)
+			if next == "" {
+				break
+			}
+			token = next
+		}
+
+		if want := benchUsers + benchSCIMOnlyUsers + 1; count != want {
+			b.Fatalf("listed %d users, want %d", count, want)
+		}
+	}
+
+	b.StopTimer()
+	reportRequests(b, s, before)
+	reportSyncTime(b, clock, start)
+}
+
+// BenchmarkGroupGrants lists all groups and the grants of each, with and
+// without prefetching group details. Allocations include those of the fake
+// server, which runs in process.
+func BenchmarkGroupGrants(b *testing.B) {
+	ctx := context.Background()
+	s := newBenchServer(b)
+	clock := &benchClock{}
+	nt := newBenchConnector(ctx, b, s, clock)
+
+	for _, concurrency := range []int{0, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			groups := groupBuilder(nt.client, nt.scimClient, nil, nil, concurrency)
+
+			b.ReportAllocs()
+			before := len(s.Requests())
+			start := clock.Now()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				resources, _, _, err := groups.List(ctx, nil, &pagination.Token{})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				var count int
+				for _, resource := range resources {
+					grants, _, _, err := groups.Grants(ctx, resource, &pagination.Token{})
+					if err != nil {
+						b.Fatal(err)
+					}
+					count += len(grants)
+				}
+
+				if want := benchGroups * benchMembersPerGroup; count != want {
+					b.Fatalf("got %d grants, want %d", count, want)
+				}
+			}
+
+			b.StopTimer()
+			reportRequests(b, s, before)
+			reportSyncTime(b, clock, start)
+		})
+	}
+}
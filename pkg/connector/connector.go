@@ -2,7 +2,9 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 
 	notionScim "github.com/conductorone/baton-notion/pkg/notion"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -29,24 +31,68 @@ var (
 			v2.ResourceType_TRAIT_GROUP,
 		},
 	}
+	resourceTypeDatabase = &v2.ResourceType{
+		Id:          "database",
+		DisplayName: "Database",
+	}
+	resourceTypeDatabaseRow = &v2.ResourceType{
+		Id:          "database_row",
+		DisplayName: "Database Row",
+	}
+	resourceTypePage = &v2.ResourceType{
+		Id:          "page",
+		DisplayName: "Page",
+	}
+	resourceTypePublic = &v2.ResourceType{
+		Id:          "public",
+		DisplayName: "Public",
+		Annotations: annotationsForUserResourceType(),
+	}
 )
 
 type Notion struct {
-	client     *notion.Client
-	scimClient *notionScim.ScimClient
+	client                   *notion.Client
+	apiClient                *notionScim.APIClient
+	scimClient               *notionScim.ScimClient
+	scimStore                *scimStore
+	databaseIDs              []string
+	databasePeopleProperties []string
+	ticketDatabaseID         string
+	discoverGuests           bool
+	publicPages              bool
+	internalEmailDomains     []string
+	filter                   *filter
+	groupFetchConcurrency    int
+	pageSize                 int
 }
 
 func (nt *Notion) ResourceSyncers(_ context.Context) []connectorbuilder.ResourceSyncer {
+	var syncers []connectorbuilder.ResourceSyncer
+	if nt.client != nil {
+		syncers = append(syncers, userBuilder(nt.client, nt.scimClient, nt.scimStore, nt.discoverGuests, nt.internalEmailDomains, nt.filter, nt.pageSize))
+	} else {
+		syncers = append(syncers, scimUserBuilder(nt.scimClient, nt.scimStore, nt.internalEmailDomains, nt.filter, nt.pageSize))
+	}
+
 	if nt.scimClient != nil {
-		return []connectorbuilder.ResourceSyncer{
-			userBuilder(nt.client),
-			groupBuilder(nt.client, nt.scimClient),
-		}
+		syncers = append(syncers, groupBuilder(nt.client, nt.scimClient, nt.scimStore, nt.filter, nt.groupFetchConcurrency))
+	}
+
+	if len(nt.databaseIDs) > 0 {
+		syncers = append(syncers,
+			databaseBuilder(nt.client, nt.databaseIDs),
+			databaseRowBuilder(nt.client, nt.databasePeopleProperties, nt.pageSize),
+		)
 	}
 
-	return []connectorbuilder.ResourceSyncer{
-		userBuilder(nt.client),
+	if nt.publicPages {
+		syncers = append(syncers,
+			publicPageBuilder(nt.apiClient, nt.pageSize),
+			publicBuilder(),
+		)
 	}
+
+	return syncers
 }
 
 // Metadata returns metadata about the connector.
@@ -57,30 +103,153 @@ func (nt *Notion) Metadata(_ context.Context) (*v2.ConnectorMetadata, error) {
 	}, nil
 }
 
-// Validate hits the Notion API to validate that the API key passed works.
+// Validate hits the Notion API to validate that the API key passed works. When
+// only a SCIM token is configured, the SCIM API is used instead.
 func (nt *Notion) Validate(ctx context.Context) (annotations.Annotations, error) {
+	if nt.client == nil {
+		_, err := nt.scimClient.GetUsers(ctx, 1, 1, notionScim.Filter{})
+		if err != nil {
+			return nil, fmt.Errorf("notion-connector: failed to authenticate with SCIM token: %w", err)
+		}
+
+		return nil, nil
+	}
+
 	_, err := nt.client.FindUserByID(ctx, "me")
 	if err != nil {
-		return nil, fmt.Errorf("notion-connector: failed to authenticate. Error: %w", err)
+		return nil, wrapError(err, "notion-connector: failed to authenticate")
 	}
 
 	return nil, nil
 }
 
-// New returns the Notion connector.
-func New(ctx context.Context, apiKey string, scimToken string) (*Notion, error) {
-	var scimClient *notionScim.ScimClient
-	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
+// New returns the Notion connector, configured by opts. Either an API key or
+// a SCIM token must be set; with only a SCIM token, users and groups are
+// synced through the SCIM API. Syncing databases, ticketing, guest discovery
+// and public pages need an API key. All requests share one rate limiter. With
+// a state directory, SCIM groups are synced incrementally.
+func New(ctx context.Context, opts ...Option) (*Notion, error) {
+	o := options{
+		logger:            ctxzap.Extract(ctx),
+		clock:             notionScim.SystemClock{},
+		requestsPerSecond: notionScim.DefaultRequestsPerSecond,
+		pageSize:          defaultPageSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.apiKey == "" && o.scimToken == "" {
+		return nil, errors.New("notion-connector: an API key or a SCIM token is required")
+	}
+	if o.apiKey == "" && (len(o.databaseIDs) > 0 || o.ticketDatabaseID != "" || o.discoverGuests || o.publicPages) {
+		return nil, errors.New("notion-connector: syncing databases, ticketing, guest discovery and public pages require an API key")
+	}
+
+	if o.recordDir != "" && o.replayDir != "" {
+		return nil, errors.New("notion-connector: requests can't be recorded and replayed at the same time")
+	}
+	if o.stateDir != "" && o.scimToken == "" {
+		return nil, errors.New("notion-connector: incremental sync requires a SCIM token")
+	}
+
+	if o.groupFetchConcurrency < 0 {
+		return nil, fmt.Errorf("notion-connector: group fetch concurrency must not be negative, got %d", o.groupFetchConcurrency)
+	}
+	if o.requestsPerSecond < 0 {
+		return nil, fmt.Errorf("notion-connector: requests per second must not be negative, got %d", o.requestsPerSecond)
+	}
+	if o.pageSize < 1 || o.pageSize > maxPageSize {
+		return nil, fmt.Errorf("notion-connector: page size must be between 1 and %d, got %d", maxPageSize, o.pageSize)
+	}
+	if o.scimPageSize < 0 {
+		return nil, fmt.Errorf("notion-connector: SCIM page size must not be negative, got %d", o.scimPageSize)
+	}
+
+	f, err := newFilter(o.filters)
 	if err != nil {
 		return nil, err
 	}
 
-	if scimToken != "" {
-		scimClient = notionScim.NewScimClient(scimToken, httpClient)
+	httpClient, err := newHTTPClient(ctx, &o)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Notion{
-		client:     notion.NewClient(apiKey, notion.WithHTTPClient(httpClient)),
-		scimClient: scimClient,
-	}, nil
+	nt := &Notion{
+		databaseIDs:              o.databaseIDs,
+		databasePeopleProperties: o.databasePeopleProperties,
+		ticketDatabaseID:         o.ticketDatabaseID,
+		discoverGuests:           o.discoverGuests,
+		publicPages:              o.publicPages,
+		internalEmailDomains:     o.internalEmailDomains,
+		filter:                   f,
+		groupFetchConcurrency:    o.groupFetchConcurrency,
+		pageSize:                 o.pageSize,
+	}
+
+	if o.scimToken != "" {
+		nt.scimClient = notionScim.NewScimClient(o.scimToken, httpClient)
+		if o.scimPageSize > 0 {
+			nt.scimClient.SetPageSize(o.scimPageSize)
+		}
+		if o.stateDir != "" {
+			nt.scimStore = newSCIMStore(nt.scimClient, o.clock, o.stateDir, o.groupFetchConcurrency)
+		}
+	}
+
+	if o.apiKey != "" {
+		nt.client = notion.NewClient(o.apiKey, notion.WithHTTPClient(httpClient))
+		nt.apiClient = notionScim.NewAPIClient(o.apiKey, httpClient)
+	}
+
+	return nt, nil
+}
+
+// newHTTPClient returns the client all requests are sent with. Its transport
+// rate limits requests, records or replays them, and rewrites their base URLs,
+// in that order.
+func newHTTPClient(ctx context.Context, o *options) (*http.Client, error) {
+	var httpClient *http.Client
+	if o.httpClient != nil {
+		c := *o.httpClient
+		httpClient = &c
+	} else {
+		var err error
+		httpClient, err = uhttp.NewClient(ctx, uhttp.WithLogger(true, o.logger))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.apiBaseURL != "" || o.scimBaseURL != "" {
+		transport, err := notionScim.BaseURLTransport(httpClient.Transport, o.apiBaseURL, o.scimBaseURL)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = transport
+	}
+
+	switch {
+	case o.replayDir != "":
+		replayer, err := notionScim.NewReplayer(o.replayDir)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = replayer
+	case o.recordDir != "":
+		recorder, err := notionScim.NewRecorder(o.recordDir, httpClient.Transport, o.apiKey, o.scimToken)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = recorder
+	}
+
+	// Replayed requests never reach Notion, so they aren't rate limited.
+	if o.replayDir == "" && o.requestsPerSecond > 0 {
+		limiter := notionScim.NewRateLimiter(o.requestsPerSecond, o.clock)
+		httpClient.Transport = limiter.Transport(httpClient.Transport)
+	}
+
+	return httpClient, nil
 }
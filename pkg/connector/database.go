@@ -0,0 +1,90 @@
+package connector
+
+import (
+	"context"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/dstotijn/go-notion"
+)
+
+type databaseResourceType struct {
+	resourceType *v2.ResourceType
+	client       *notion.Client
+	databaseIDs  []string
+}
+
+func (d *databaseResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return d.resourceType
+}
+
+// plainText joins the plain text content of a Notion rich text value.
+func plainText(richText []notion.RichText) string {
+	var sb strings.Builder
+	for _, rt := range richText {
+		sb.WriteString(rt.PlainText)
+	}
+	return sb.String()
+}
+
+// Create a new connector resource for a Notion database.
+func databaseResource(db notion.Database) (*v2.Resource, error) {
+	name := plainText(db.Title)
+	if name == "" {
+		name = db.ID
+	}
+
+	ret, err := rs.NewResource(
+		name,
+		resourceTypeDatabase,
+		db.ID,
+		rs.WithAnnotation(
+			&v2.ChildResourceType{ResourceTypeId: resourceTypeDatabaseRow.Id},
+			&v2.ExternalLink{Url: db.URL},
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// List returns the databases configured for syncing. Each one is fetched so
+// that the resource carries its current title.
+func (d *databaseResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	var rv []*v2.Resource
+	for _, databaseID := range d.databaseIDs {
+		db, err := d.client.FindDatabaseByID(ctx, databaseID)
+		if err != nil {
+			return nil, "", nil, wrapError(err, "notion-connector: failed to get database %s", databaseID)
+		}
+
+		dr, err := databaseResource(db)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, dr)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (d *databaseResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (d *databaseResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func databaseBuilder(client *notion.Client, databaseIDs []string) *databaseResourceType {
+	return &databaseResourceType{
+		resourceType: resourceTypeDatabase,
+		client:       client,
+		databaseIDs:  databaseIDs,
+	}
+}
@@ -0,0 +1,277 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	grant "github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/dstotijn/go-notion"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// databaseRowPeopleKey is the key of the annotation that carries the users
+// listed in the People properties of a row from List to Grants.
+const databaseRowPeopleKey = "people_properties"
+
+// databaseRowPeopleLimit is the number of users Notion returns at most for a
+// People property of a page. Longer lists are cut off, and are read in full
+// from the page property endpoint.
+const databaseRowPeopleLimit = 25
+
+type databaseRowResourceType struct {
+	resourceType     *v2.ResourceType
+	client           *notion.Client
+	peopleProperties []string
+	pageSize         int
+}
+
+func (d *databaseRowResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return d.resourceType
+}
+
+// databaseRowTitle returns the value of the title property of a database row.
+func databaseRowTitle(props notion.DatabasePageProperties) string {
+	for _, prop := range props {
+		if prop.Type == notion.DBPropTypeTitle {
+			return plainText(prop.Title)
+		}
+	}
+	return ""
+}
+
+// databaseRowPeople returns the IDs of the users listed in the given People
+// properties of a row, by property.
+func databaseRowPeople(props notion.DatabasePageProperties, peopleProperties []string) map[string][]string {
+	people := make(map[string][]string)
+	for _, property := range peopleProperties {
+		prop, ok := props[property]
+		if !ok || prop.Type != notion.DBPropTypePeople {
+			continue
+		}
+
+		for _, person := range prop.People {
+			people[property] = append(people[property], person.ID)
+		}
+	}
+	return people
+}
+
+// people returns the IDs of the users listed in the configured People
+// properties of a row, by property. Lists Notion may have cut off are read
+// again in full.
+func (d *databaseRowResourceType) people(ctx context.Context, pageID string, props notion.DatabasePageProperties) (map[string][]string, error) {
+	people := databaseRowPeople(props, d.peopleProperties)
+	for property, ids := range people {
+		if len(ids) < databaseRowPeopleLimit {
+			continue
+		}
+
+		ids, err := d.propertyPeople(ctx, pageID, props[property].ID)
+		if err != nil {
+			return nil, err
+		}
+		people[property] = ids
+	}
+	return people, nil
+}
+
+// propertyPeople returns the IDs of all users listed in a People property of
+// a page, one page of the property at a time.
+func (d *databaseRowResourceType) propertyPeople(ctx context.Context, pageID, propID string) ([]string, error) {
+	var ids []string
+	query := &notion.PaginationQuery{PageSize: d.pageSize}
+	for {
+		res, err := d.client.FindPagePropertyByID(ctx, pageID, propID, query)
+		if err != nil {
+			return nil, wrapError(err, "notion-connector: failed to get property %s of database row %s", propID, pageID)
+		}
+
+		for _, item := range res.Results {
+			ids = append(ids, item.People.ID)
+		}
+		if !res.HasMore || res.NextCursor == "" {
+			return ids, nil
+		}
+		query = &notion.PaginationQuery{PageSize: d.pageSize, StartCursor: res.NextCursor}
+	}
+}
+
+// databaseRowPeopleAnnotation records the users listed in the People
+// properties of a row, so that Grants doesn't request the row again.
+func databaseRowPeopleAnnotation(people map[string][]string) (*structpb.Struct, error) {
+	values := make(map[string]interface{}, len(people))
+	for property, ids := range people {
+		list := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			list = append(list, id)
+		}
+		values[property] = list
+	}
+
+	return structpb.NewStruct(map[string]interface{}{
+		databaseRowPeopleKey: values,
+	})
+}
+
+// databaseRowPeopleFromAnnotations returns the users recorded by
+// databaseRowPeopleAnnotation, and false when the annotation is missing.
+func databaseRowPeopleFromAnnotations(annos annotations.Annotations) (map[string][]string, bool) {
+	for _, a := range annos {
+		s := &structpb.Struct{}
+		if !a.MessageIs(s) || a.UnmarshalTo(s) != nil {
+			continue
+		}
+
+		value, ok := s.GetFields()[databaseRowPeopleKey]
+		if !ok {
+			continue
+		}
+
+		people := make(map[string][]string)
+		for property, ids := range value.GetStructValue().GetFields() {
+			for _, id := range ids.GetListValue().GetValues() {
+				people[property] = append(people[property], id.GetStringValue())
+			}
+		}
+		return people, true
+	}
+	return nil, false
+}
+
+// Create a new connector resource for a row of a Notion database.
+func databaseRowResource(page notion.Page, parentResourceID *v2.ResourceId, people map[string][]string) (*v2.Resource, error) {
+	name := page.ID
+	if props, ok := page.Properties.(notion.DatabasePageProperties); ok {
+		if title := databaseRowTitle(props); title != "" {
+			name = title
+		}
+	}
+
+	peopleAnnotation, err := databaseRowPeopleAnnotation(people)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := rs.NewResource(
+		name,
+		resourceTypeDatabaseRow,
+		page.ID,
+		rs.WithParentResourceID(parentResourceID),
+		rs.WithAnnotation(&v2.ExternalLink{Url: page.URL}, peopleAnnotation),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (d *databaseRowResourceType) List(ctx context.Context, parentResourceID *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil {
+		return nil, "", nil, nil
+	}
+
+	var pageToken string
+	bag, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeDatabaseRow.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rowsResponse, err := d.client.QueryDatabase(ctx, parentResourceID.Resource, &notion.DatabaseQuery{PageSize: d.pageSize, StartCursor: bag.PageToken()})
+	if err != nil {
+		return nil, "", nil, wrapError(err, "notion-connector: failed to query database %s", parentResourceID.Resource)
+	}
+
+	if rowsResponse.HasMore {
+		pageToken, err = bag.NextToken(*rowsResponse.NextCursor)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+
+	var rv []*v2.Resource
+	for _, page := range rowsResponse.Results {
+		people := make(map[string][]string)
+		if props, ok := page.Properties.(notion.DatabasePageProperties); ok {
+			people, err = d.people(ctx, page.ID, props)
+			if err != nil {
+				return nil, "", nil, err
+			}
+		}
+
+		rr, err := databaseRowResource(page, parentResourceID, people)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, rr)
+	}
+
+	return rv, pageToken, nil, nil
+}
+
+// Entitlements returns one entitlement per configured People property.
+func (d *databaseRowResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	var rv []*v2.Entitlement
+
+	for _, property := range d.peopleProperties {
+		assigmentOptions := []ent.EntitlementOption{
+			ent.WithGrantableTo(resourceTypeUser),
+			ent.WithDescription(fmt.Sprintf("Listed as %s of %s in Notion", property, resource.DisplayName)),
+			ent.WithDisplayName(fmt.Sprintf("%s %s", resource.DisplayName, property)),
+		}
+
+		rv = append(rv, ent.NewAssignmentEntitlement(resource, property, assigmentOptions...))
+	}
+
+	return rv, "", nil, nil
+}
+
+// Grants returns a grant for every user listed in the configured People
+// properties of the row. The users are read from the annotation List set,
+// and the row is only requested again for resources without it.
+func (d *databaseRowResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	var rv []*v2.Grant
+
+	people, ok := databaseRowPeopleFromAnnotations(resource.Annotations)
+	if !ok {
+		page, err := d.client.FindPageByID(ctx, resource.Id.Resource)
+		if err != nil {
+			return nil, "", nil, wrapError(err, "notion-connector: failed to get database row %s", resource.Id.Resource)
+		}
+
+		props, ok := page.Properties.(notion.DatabasePageProperties)
+		if !ok {
+			return nil, "", nil, nil
+		}
+		people, err = d.people(ctx, page.ID, props)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+
+	for _, property := range d.peopleProperties {
+		for _, id := range people[property] {
+			principalID, err := rs.NewResourceID(resourceTypeUser, id)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			rv = append(rv, grant.NewGrant(resource, property, principalID))
+		}
+	}
+
+	return rv, "", nil, nil
+}
+
+func databaseRowBuilder(client *notion.Client, peopleProperties []string, pageSize int) *databaseRowResourceType {
+	return &databaseRowResourceType{
+		resourceType:     resourceTypeDatabaseRow,
+		client:           client,
+		peopleProperties: peopleProperties,
+		pageSize:         pageSize,
+	}
+}
@@ -0,0 +1,80 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/dstotijn/go-notion"
+)
+
+// databaseRow returns a row of db-1 with n users in its Owner property.
+func databaseRow(id string, n int) notion.Page {
+	owners := make([]notion.User, 0, n)
+	for i := 0; i < n; i++ {
+		owners = append(owners, notion.User{BaseUser: notion.BaseUser{ID: fmt.Sprintf("user-%d", i)}})
+	}
+
+	return notion.Page{
+		ID:     id,
+		Parent: notion.Parent{Type: notion.ParentTypeDatabase, DatabaseID: "db-1"},
+		Properties: notion.DatabasePageProperties{
+			"Name": notion.DatabasePageProperty{
+				ID:    "title",
+				Type:  notion.DBPropTypeTitle,
+				Title: []notion.RichText{{PlainText: id}},
+			},
+			"Owner": notion.DatabasePageProperty{
+				ID:     "owner",
+				Type:   notion.DBPropTypePeople,
+				People: owners,
+			},
+		},
+	}
+}
+
+func TestDatabaseRowGrantsBeyondTruncatedPeople(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	s.AddDatabaseRows("db-1", databaseRow("row-small", 2), databaseRow("row-large", 30))
+
+	client := notion.NewClient("api-key", notion.WithHTTPClient(s.Client()))
+	rows := databaseRowBuilder(client, []string{"Owner"}, 10)
+
+	resources, _, _, err := rows.List(ctx, &v2.ResourceId{ResourceType: resourceTypeDatabase.Id, Resource: "db-1"}, &pagination.Token{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 2 {
+		t.Fatalf("got %d rows, want 2", len(resources))
+	}
+
+	for i, want := range []int{2, 30} {
+		grants, _, _, err := rows.Grants(ctx, resources[i], &pagination.Token{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(grants) != want {
+			t.Errorf("got %d grants for %s, want %d", len(grants), resources[i].Id.Resource, want)
+		}
+	}
+
+	// Only the row with a cut off list is read again, 10 users at a time.
+	var properties int
+	for _, r := range s.Requests() {
+		if strings.HasPrefix(r, "GET /v1/pages/") {
+			if r != "GET /v1/pages/row-large/properties/owner" {
+				t.Errorf("got request %s", r)
+			}
+			properties++
+		}
+	}
+	if properties != 3 {
+		t.Errorf("got %d page property requests, want 3", properties)
+	}
+}
@@ -0,0 +1,67 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/dstotijn/go-notion"
+	"google.golang.org/grpc/codes"
+)
+
+// notionErrorCodes maps Notion API error codes to gRPC status codes.
+// See: https://developers.notion.com/reference/status-codes.
+var notionErrorCodes = map[string]codes.Code{
+	"invalid_json":                    codes.InvalidArgument,
+	"invalid_request_url":             codes.InvalidArgument,
+	"invalid_request":                 codes.InvalidArgument,
+	"validation_error":                codes.InvalidArgument,
+	"missing_version":                 codes.InvalidArgument,
+	"unauthorized":                    codes.Unauthenticated,
+	"restricted_resource":             codes.PermissionDenied,
+	"object_not_found":                codes.NotFound,
+	"conflict_error":                  codes.Aborted,
+	"rate_limited":                    codes.Unavailable,
+	"internal_server_error":           codes.Unavailable,
+	"service_unavailable":             codes.Unavailable,
+	"database_connection_unavailable": codes.Unavailable,
+	"gateway_timeout":                 codes.DeadlineExceeded,
+}
+
+// notionHTTPStatusCodes maps HTTP status codes to gRPC status codes, for
+// errors that carry no Notion error code.
+var notionHTTPStatusCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.Aborted,
+	http.StatusTooManyRequests:     codes.Unavailable,
+	http.StatusInternalServerError: codes.Unavailable,
+	http.StatusBadGateway:          codes.Unavailable,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+}
+
+// wrapError annotates an error returned by the Notion API client. Notion API
+// errors become gRPC status errors, so that the SDK retries transient failures
+// and reports bad credentials as such. The original error stays in the chain.
+func wrapError(err error, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+
+	var apiErr *notion.APIError
+	if !errors.As(err, &apiErr) {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+
+	code, ok := notionErrorCodes[apiErr.Code]
+	if !ok {
+		code, ok = notionHTTPStatusCodes[apiErr.Status]
+	}
+	if !ok {
+		code = codes.Unknown
+	}
+
+	return uhttp.WrapErrors(code, fmt.Sprintf("%s: %s", msg, apiErr.Error()), err)
+}
@@ -0,0 +1,128 @@
+package connector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Filters select the users and groups to sync. Empty filters sync everything.
+type Filters struct {
+	// IncludeEmailDomains limits the synced people to those with an email
+	// address in one of the domains or their subdomains.
+	IncludeEmailDomains []string
+	// ExcludeEmailDomains skips people with an email address in one of the
+	// domains or their subdomains.
+	ExcludeEmailDomains []string
+	// IncludeGroups limits the synced groups to those whose display name
+	// matches one of the regular expressions.
+	IncludeGroups []string
+	// ExcludeGroups skips groups whose display name matches one of the
+	// regular expressions.
+	ExcludeGroups []string
+	// SkipBots skips bot users.
+	SkipBots bool
+}
+
+// filter is the compiled form of Filters.
+type filter struct {
+	includeEmailDomains []string
+	excludeEmailDomains []string
+	includeGroups       []*regexp.Regexp
+	excludeGroups       []*regexp.Regexp
+	skipBots            bool
+}
+
+func compileRegexps(exprs []string) ([]*regexp.Regexp, error) {
+	var rv []*regexp.Regexp
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("notion-connector: invalid group filter %q: %w", expr, err)
+		}
+		rv = append(rv, re)
+	}
+	return rv, nil
+}
+
+func newFilter(f Filters) (*filter, error) {
+	includeGroups, err := compileRegexps(f.IncludeGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	excludeGroups, err := compileRegexps(f.ExcludeGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filter{
+		includeEmailDomains: f.IncludeEmailDomains,
+		excludeEmailDomains: f.ExcludeEmailDomains,
+		includeGroups:       includeGroups,
+		excludeGroups:       excludeGroups,
+		skipBots:            f.SkipBots,
+	}, nil
+}
+
+// emailInDomains reports whether the domain of email is one of domains or a
+// subdomain of one of them.
+func emailInDomains(email string, domains []string) bool {
+	_, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok {
+		return false
+	}
+
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "@"))
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// filtersUsers reports whether any user is skipped at all.
+func (f *filter) filtersUsers() bool {
+	return f != nil && (len(f.includeEmailDomains) > 0 || len(f.excludeEmailDomains) > 0 || f.skipBots)
+}
+
+// allowsUser reports whether a user with the given email address is synced.
+// Email domain filters only apply to people, bots are only skipped by SkipBots.
+func (f *filter) allowsUser(email string, bot bool) bool {
+	if f == nil {
+		return true
+	}
+
+	if bot {
+		return !f.skipBots
+	}
+
+	if len(f.includeEmailDomains) > 0 && !emailInDomains(email, f.includeEmailDomains) {
+		return false
+	}
+
+	return !emailInDomains(email, f.excludeEmailDomains)
+}
+
+func matchesAny(s string, exprs []*regexp.Regexp) bool {
+	for _, re := range exprs {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsGroup reports whether a group with the given display name is synced.
+func (f *filter) allowsGroup(name string) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.includeGroups) > 0 && !matchesAny(name, f.includeGroups) {
+		return false
+	}
+
+	return !matchesAny(name, f.excludeGroups)
+}
@@ -2,7 +2,11 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
 	notionScim "github.com/conductorone/baton-notion/pkg/notion"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -12,6 +16,9 @@ import (
 	grant "github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/dstotijn/go-notion"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 const memberEntitlement = "member"
@@ -20,6 +27,53 @@ type groupResourceType struct {
 	resourceType *v2.ResourceType
 	scimClient   *notionScim.ScimClient
 	client       *notion.Client
+	filter       *filter
+	// scimStore, when set, keeps the groups and their members between syncs,
+	// and replaces prefetching.
+	scimStore *scimStore
+
+	// SCIM users by normalized ID, taken from the store or looked up once
+	// each to resolve members the public API can't. A nil user is one SCIM
+	// doesn't know either.
+	mu        sync.Mutex
+	scimUsers map[string]*notionScim.User
+
+	// prefetchConcurrency is the number of workers fetching group details
+	// ahead of Grants. Prefetching is disabled when it is zero.
+	prefetchConcurrency int
+	prefetchMu          sync.Mutex
+	prefetched          map[string]*groupPrefetch
+	// prefetchCancel stops the workers started by the previous List.
+	prefetchCancel context.CancelFunc
+	// prefetchIdleTimeout is how long the workers wait for Grants to take a
+	// fetched group before they give up on the prefetch.
+	prefetchIdleTimeout time.Duration
+
+	// membership applies the changes of Grant and Revoke.
+	membership *membershipBatcher
+}
+
+// prefetchWindowPerWorker is how many fetched groups per worker may wait for
+// Grants before the workers pause, which bounds the members held in memory.
+const prefetchWindowPerWorker = 2
+
+// defaultPrefetchIdleTimeout is how long the prefetch waits for Grants. The
+// connector isn't told when a sync ends, so a prefetch that a failed or
+// finished sync left behind is stopped after it, rather than by the next
+// List only.
+const defaultPrefetchIdleTimeout = 10 * time.Minute
+
+// groupPrefetch is the pending result of fetching the details of a group.
+type groupPrefetch struct {
+	id   string
+	done chan struct{}
+	// taken is set by whoever fetches the group: a worker, or getGroup when a
+	// worker hasn't got to it yet.
+	taken bool
+	// window is released when Grants takes the fetched group.
+	window chan struct{}
+	group  notionScim.Group
+	err    error
 }
 
 func (g *groupResourceType) ResourceType(_ context.Context) *v2.ResourceType {
@@ -49,24 +103,51 @@ func groupResource(group *notionScim.Group) (*v2.Resource, error) {
 }
 
 func (g *groupResourceType) List(ctx context.Context, _ *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	groups, err := g.scimClient.GetPaginatedGroups(ctx)
+	// Members are looked up again in every sync.
+	g.mu.Lock()
+	g.scimUsers = nil
+	g.mu.Unlock()
+
+	groups, err := g.listGroups(ctx)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("notion-connector: failed to list groups: %w", err)
+		return nil, "", nil, err
 	}
 
 	var rv []*v2.Resource
+	var groupIDs []string
 	for _, group := range groups {
+		if !g.filter.allowsGroup(group.DisplayName) {
+			continue
+		}
+
 		groupCopy := group
 		ur, err := groupResource(&groupCopy)
 		if err != nil {
 			return nil, "", nil, err
 		}
 		rv = append(rv, ur)
+		groupIDs = append(groupIDs, group.ID)
+	}
+
+	if g.prefetchConcurrency > 0 && g.scimStore == nil {
+		g.prefetchGroups(ctx, groupIDs)
 	}
 
 	return rv, "", nil, nil
 }
 
+func (g *groupResourceType) listGroups(ctx context.Context) ([]notionScim.Group, error) {
+	if g.scimStore != nil {
+		return g.scimStore.refreshGroups(ctx)
+	}
+
+	groups, err := g.scimClient.GetPaginatedGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("notion-connector: failed to list groups: %w", err)
+	}
+	return groups, nil
+}
+
 func (g *groupResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	var rv []*v2.Entitlement
 
@@ -83,35 +164,297 @@ func (g *groupResourceType) Entitlements(_ context.Context, resource *v2.Resourc
 }
 
 func (g *groupResourceType) Grants(ctx context.Context, resource *v2.Resource, token *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
 	var rv []*v2.Grant
 
-	group, err := g.scimClient.GetGroup(ctx, resource.Id.Resource)
+	group, err := g.getGroup(ctx, resource.Id.Resource)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
+	var skipped []interface{}
 	for _, member := range group.Members {
 		memberCopy := member
+
+		// Without an API key, members can't be resolved. SCIM and the public
+		// API share user IDs, so the member ID is used as is.
+		if g.client == nil {
+			allowed, err := g.scimMemberAllowed(ctx, memberCopy.Value)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			if !allowed {
+				continue
+			}
+
+			principalID, err := rs.NewResourceID(resourceTypeUser, memberCopy.Value)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			rv = append(rv, grant.NewGrant(resource, memberEntitlement, principalID))
+			continue
+		}
+
 		user, err := g.client.FindUserByID(ctx, memberCopy.Value)
 		if err != nil {
-			return nil, "", nil, err
+			if !isNotFound(err) {
+				return nil, "", nil, wrapError(err, "notion-connector: failed to get user %s", memberCopy.Value)
+			}
+
+			// Users known to SCIM only are synced from SCIM, under their
+			// SCIM ID.
+			scimUser, ok, err := g.scimUser(ctx, memberCopy.Value)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			if ok {
+				if !g.filter.allowsUser(scimUser.PrimaryEmail(), false) {
+					continue
+				}
+
+				principalID, err := rs.NewResourceID(resourceTypeUser, scimUser.ID)
+				if err != nil {
+					return nil, "", nil, err
+				}
+				rv = append(rv, grant.NewGrant(resource, memberEntitlement, principalID))
+				continue
+			}
+
+			// A member that was hard-deleted can't be resolved. Skip it rather
+			// than failing the sync of the whole group.
+			l.Warn("skipping group member that could not be found",
+				zap.String("group_id", group.ID),
+				zap.String("user_id", memberCopy.Value),
+			)
+			skipped = append(skipped, memberCopy.Value)
+			continue
+		}
+		if !g.filter.allowsUser(publicUserEmail(user), user.Type == notion.UserTypeBot) {
+			continue
 		}
-		ur, err := userResource(ctx, user)
+
+		principalID, err := rs.NewResourceID(resourceTypeUser, user.ID)
 		if err != nil {
 			return nil, "", nil, err
 		}
 
-		grant := grant.NewGrant(resource, memberEntitlement, ur.Id)
+		grant := grant.NewGrant(resource, memberEntitlement, principalID)
 		rv = append(rv, grant)
 	}
 
-	return rv, "", nil, nil
+	var annos annotations.Annotations
+	if len(skipped) > 0 {
+		skippedMembers, err := structpb.NewStruct(map[string]interface{}{
+			"skipped_members_count": len(skipped),
+			"skipped_member_ids":    skipped,
+		})
+		if err != nil {
+			return nil, "", nil, err
+		}
+		annos.Append(skippedMembers)
+	}
+
+	return rv, "", annos, nil
+}
+
+// prefetchGroups starts fetching the details of the groups in the background,
+// so that Grants finds them ready. The requests outlive the List call, so
+// they run under a context of their own, which the next List cancels. The
+// workers stay a bounded number of groups ahead of Grants, and stop when
+// Grants takes no group for prefetchIdleTimeout, dropping the groups they
+// fetched. Grants then fetches the groups itself.
+func (g *groupResourceType) prefetchGroups(ctx context.Context, groupIDs []string) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	// Workers take the pending results from the queue rather than from the
+	// map, which getGroup removes them from.
+	window := make(chan struct{}, g.prefetchConcurrency*prefetchWindowPerWorker)
+	pending := make(map[string]*groupPrefetch, len(groupIDs))
+	queue := make(chan *groupPrefetch, len(groupIDs))
+	for _, id := range groupIDs {
+		p := &groupPrefetch{id: id, done: make(chan struct{}), window: window}
+		pending[id] = p
+		queue <- p
+	}
+	close(queue)
+
+	g.prefetchMu.Lock()
+	if g.prefetchCancel != nil {
+		g.prefetchCancel()
+	}
+	g.prefetchCancel = cancel
+	g.prefetched = pending
+	g.prefetchMu.Unlock()
+
+	stop := func() {
+		cancel()
+		g.prefetchMu.Lock()
+		defer g.prefetchMu.Unlock()
+		for id, p := range pending {
+			if g.prefetched[id] == p {
+				delete(g.prefetched, id)
+			}
+		}
+	}
+
+	for i := 0; i < g.prefetchConcurrency; i++ {
+		go func() {
+			for p := range queue {
+				idle := time.NewTimer(g.prefetchIdleTimeout)
+				select {
+				case <-ctx.Done():
+					idle.Stop()
+					return
+				case <-idle.C:
+					ctxzap.Extract(ctx).Debug("stopping the group prefetch, Grants took no group", zap.Duration("timeout", g.prefetchIdleTimeout))
+					stop()
+					return
+				case window <- struct{}{}:
+					idle.Stop()
+				}
+
+				g.prefetchMu.Lock()
+				taken := p.taken
+				p.taken = true
+				g.prefetchMu.Unlock()
+				if taken {
+					<-window
+					continue
+				}
+
+				p.group, p.err = g.scimClient.GetGroup(ctx, p.id)
+				close(p.done)
+			}
+		}()
+	}
+}
+
+// getGroup returns the details of a group, from the store or from a prefetch
+// of it when one was started. Groups the workers haven't got to yet are
+// fetched right away.
+func (g *groupResourceType) getGroup(ctx context.Context, groupID string) (notionScim.Group, error) {
+	if g.scimStore != nil {
+		if group, ok := g.scimStore.group(groupID); ok {
+			return group, nil
+		}
+	}
+
+	g.prefetchMu.Lock()
+	p, ok := g.prefetched[groupID]
+	delete(g.prefetched, groupID)
+	fetched := ok && p.taken
+	if ok {
+		p.taken = true
+	}
+	g.prefetchMu.Unlock()
+
+	if !fetched {
+		return g.scimClient.GetGroup(ctx, groupID)
+	}
+
+	select {
+	case <-ctx.Done():
+		// The slot is released once the worker is done, so that the workers
+		// don't stall on a group nobody waits for.
+		go func() {
+			<-p.done
+			<-p.window
+		}()
+		return notionScim.Group{}, ctx.Err()
+	case <-p.done:
+	}
+	<-p.window
+
+	// The prefetch was canceled by a later List, so the group is fetched
+	// again.
+	if errors.Is(p.err, context.Canceled) {
+		return g.scimClient.GetGroup(ctx, groupID)
+	}
+	return p.group, p.err
+}
+
+// scimMemberAllowed reports whether a group member passes the user filters,
+// looking the member up among the SCIM users.
+func (g *groupResourceType) scimMemberAllowed(ctx context.Context, userID string) (bool, error) {
+	if !g.filter.filtersUsers() {
+		return true, nil
+	}
+
+	user, ok, err := g.scimUser(ctx, userID)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return g.filter.allowsUser(user.PrimaryEmail(), false), nil
+}
+
+// scimUser returns the SCIM user with the given ID, from the store when there
+// is one. Otherwise the user is looked up by ID the first time, rather than
+// listing every SCIM user for the few members that need it.
+func (g *groupResourceType) scimUser(ctx context.Context, userID string) (notionScim.User, bool, error) {
+	id := normalizeID(userID)
+
+	g.mu.Lock()
+	if g.scimStore != nil && g.scimUsers == nil {
+		users, err := g.scimStore.users(ctx, false)
+		if err != nil {
+			g.mu.Unlock()
+			return notionScim.User{}, false, err
+		}
+		g.scimUsers = make(map[string]*notionScim.User, len(users))
+		for _, user := range users {
+			g.scimUsers[normalizeID(user.ID)] = &user
+		}
+	}
+	user, known := g.scimUsers[id]
+	g.mu.Unlock()
+	if g.scimStore != nil || known {
+		if user == nil {
+			return notionScim.User{}, false, nil
+		}
+		return *user, true, nil
+	}
+
+	found, err := g.scimClient.GetUser(ctx, userID)
+	switch {
+	case err == nil:
+		user = &found
+	case !notionScim.IsNotFound(err):
+		return notionScim.User{}, false, fmt.Errorf("notion-connector: failed to get SCIM user %s: %w", userID, err)
+	}
+
+	g.mu.Lock()
+	if g.scimUsers == nil {
+		g.scimUsers = make(map[string]*notionScim.User)
+	}
+	g.scimUsers[id] = user
+	g.mu.Unlock()
+
+	if user == nil {
+		return notionScim.User{}, false, nil
+	}
+	return *user, true, nil
+}
+
+// isNotFound reports whether err is a Notion API error for a missing object.
+func isNotFound(err error) bool {
+	if errors.Is(err, notion.ErrObjectNotFound) {
+		return true
+	}
+
+	var apiErr *notion.APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
 }
 
-func groupBuilder(client *notion.Client, scimClient *notionScim.ScimClient) *groupResourceType {
+func groupBuilder(client *notion.Client, scimClient *notionScim.ScimClient, scimStore *scimStore, filter *filter, prefetchConcurrency int) *groupResourceType {
 	return &groupResourceType{
-		resourceType: resourceTypeGroup,
-		scimClient:   scimClient,
-		client:       client,
+		resourceType:        resourceTypeGroup,
+		scimClient:          scimClient,
+		client:              client,
+		scimStore:           scimStore,
+		filter:              filter,
+		prefetchConcurrency: prefetchConcurrency,
+		prefetchIdleTimeout: defaultPrefetchIdleTimeout,
+		membership:          newMembershipBatcher(scimClient),
 	}
 }
@@ -0,0 +1,132 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+)
+
+// membershipBatcher applies the group membership changes of concurrent Grant
+// and Revoke calls together, in bulk requests when Notion supports them. A
+// batch is formed by the calls that arrive while the previous batch is sent,
+// and a call that arrives alone is sent at once rather than waiting for
+// others. The SDK runs grants and revokes one at a time, so batches only form
+// when a caller, such as a program embedding the connector, runs them
+// concurrently; otherwise every change is sent on its own.
+type membershipBatcher struct {
+	client *notionScim.ScimClient
+
+	// send is held by the call sending a batch.
+	send chan struct{}
+
+	mu      sync.Mutex
+	pending []*membershipRequest
+}
+
+// membershipRequest is a change waiting to be sent, and its result.
+type membershipRequest struct {
+	change notionScim.MembershipChange
+	done   chan struct{}
+	err    error
+}
+
+func newMembershipBatcher(client *notionScim.ScimClient) *membershipBatcher {
+	return &membershipBatcher{
+		client: client,
+		send:   make(chan struct{}, 1),
+	}
+}
+
+// apply queues change and waits for its result. Once no batch is being sent,
+// the call sends every change queued so far, its own included unless an
+// earlier call already sent it. A canceled call only fails when its change
+// wasn't taken by a batch yet; otherwise it waits for the result. Batches are
+// sent without the cancellation of the call sending them, as the changes of
+// other calls are part of them.
+func (b *membershipBatcher) apply(ctx context.Context, change notionScim.MembershipChange) error {
+	req := &membershipRequest{change: change, done: make(chan struct{})}
+	b.mu.Lock()
+	b.pending = append(b.pending, req)
+	b.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		b.mu.Lock()
+		queued := slices.Contains(b.pending, req)
+		if queued {
+			b.pending = slices.DeleteFunc(b.pending, func(r *membershipRequest) bool { return r == req })
+		}
+		b.mu.Unlock()
+		if queued {
+			return ctx.Err()
+		}
+
+		// A batch took the change, which is applied whether or not this
+		// call waits, so its result is reported.
+		<-req.done
+		return req.err
+	case <-req.done:
+		return req.err
+	case b.send <- struct{}{}:
+	}
+	defer func() { <-b.send }()
+
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	if len(batch) > 0 {
+		changes := make([]notionScim.MembershipChange, 0, len(batch))
+		for _, r := range batch {
+			changes = append(changes, r.change)
+		}
+
+		errs := b.client.ChangeGroupMembers(context.WithoutCancel(ctx), changes)
+		for i, r := range batch {
+			r.err = errs[i]
+			close(r.done)
+		}
+	}
+
+	<-req.done
+	return req.err
+}
+
+// Grant adds a user to a group.
+func (g *groupResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		return nil, fmt.Errorf("notion-connector: only users can be granted group membership, not %s", principal.Id.ResourceType)
+	}
+
+	groupID := entitlement.Resource.Id.Resource
+	userID := principal.Id.Resource
+	err := g.membership.apply(ctx, notionScim.MembershipChange{GroupID: groupID, UserID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("notion-connector: failed to add user %s to group %s: %w", userID, groupID, err)
+	}
+
+	return nil, nil
+}
+
+// Revoke removes a user from a group.
+func (g *groupResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	principal := grant.Principal
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		return nil, fmt.Errorf("notion-connector: only users can be revoked group membership, not %s", principal.Id.ResourceType)
+	}
+
+	groupID := grant.Entitlement.Resource.Id.Resource
+	userID := principal.Id.Resource
+	err := g.membership.apply(ctx, notionScim.MembershipChange{GroupID: groupID, UserID: userID, Remove: true})
+	if err != nil {
+		return nil, fmt.Errorf("notion-connector: failed to remove user %s from group %s: %w", userID, groupID, err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,223 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// gateTransport announces every bulk request on entered, and holds it until
+// it receives from release.
+type gateTransport struct {
+	base    http.RoundTripper
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newGateTransport(base http.RoundTripper) *gateTransport {
+	return &gateTransport{
+		base:    base,
+		entered: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (t *gateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/Bulk") {
+		t.entered <- struct{}{}
+		<-t.release
+	}
+	return t.base.RoundTrip(req)
+}
+
+// waitPending waits until n changes are queued in b.
+func waitPending(t *testing.T, b *membershipBatcher, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.mu.Lock()
+		queued := len(b.pending)
+		b.mu.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d queued changes, want %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newMembershipTest(t *testing.T) (*notiontest.Server, *gateTransport, *groupResourceType) {
+	t.Helper()
+	s := notiontest.NewServer()
+	t.Cleanup(s.Close)
+	s.AddGroups(notionScim.Group{ID: "group-0", DisplayName: "Group 0"})
+	s.EnableBulk(100, 0)
+
+	gate := newGateTransport(s.Client().Transport)
+	scimClient := notionScim.NewScimClient("scim-token", &http.Client{Transport: gate})
+	return s, gate, groupBuilder(nil, scimClient, nil, nil, 0)
+}
+
+var (
+	testGroup       = &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: "group-0"}}
+	testEntitlement = &v2.Entitlement{Resource: testGroup, Slug: memberEntitlement}
+)
+
+func testUser(i int) *v2.Resource {
+	return &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: fmt.Sprintf("user-%d", i)}}
+}
+
+func groupMembers(s *notiontest.Server) string {
+	got, _ := s.Group("group-0")
+	var members []string
+	for _, m := range got.Members {
+		members = append(members, m.Value)
+	}
+	sort.Strings(members)
+	return strings.Join(members, " ")
+}
+
+func TestGroupGrantsShareBulkRequests(t *testing.T) {
+	ctx := context.Background()
+	s, gate, groups := newMembershipTest(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 11)
+	grantUser := func(i int) {
+		defer wg.Done()
+		_, err := groups.Grant(ctx, testUser(i), testEntitlement)
+		errs <- err
+	}
+
+	// The first grant is sent alone, and held while the others queue up.
+	wg.Add(1)
+	go grantUser(0)
+	<-gate.entered
+
+	for i := 1; i < 10; i++ {
+		wg.Add(1)
+		go grantUser(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := groups.Revoke(ctx, &v2.Grant{Entitlement: testEntitlement, Principal: testUser(0)})
+		errs <- err
+	}()
+
+	waitPending(t, groups.membership, 10)
+	close(gate.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	var bulk int
+	for _, r := range s.Requests() {
+		if r == "POST /scim/v2/Bulk" {
+			bulk++
+		}
+	}
+	if bulk != 2 {
+		t.Errorf("got %d bulk requests, want 2", bulk)
+	}
+	if got, want := groupMembers(s), "user-1 user-2 user-3 user-4 user-5 user-6 user-7 user-8 user-9"; got != want {
+		t.Errorf("got members %s, want %s", got, want)
+	}
+}
+
+func TestGroupGrantCanceledAfterBatchReportsResult(t *testing.T) {
+	ctx := context.Background()
+	s, gate, groups := newMembershipTest(t)
+
+	var wg sync.WaitGroup
+	grant := func(ctx context.Context, i int, err *error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, *err = groups.Grant(ctx, testUser(i), testEntitlement)
+		}()
+	}
+
+	var errs [3]error
+	grant(ctx, 0, &errs[0])
+	<-gate.entered
+
+	canceled, cancel := context.WithCancel(ctx)
+	grant(canceled, 1, &errs[1])
+	grant(ctx, 2, &errs[2])
+	waitPending(t, groups.membership, 2)
+
+	// The second batch takes both queued changes, and the call of user-1 is
+	// canceled while it is sent.
+	gate.release <- struct{}{}
+	<-gate.entered
+	cancel()
+	gate.release <- struct{}{}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("grant %d: %v", i, err)
+		}
+	}
+	if got, want := groupMembers(s), "user-0 user-1 user-2"; got != want {
+		t.Errorf("got members %s, want %s", got, want)
+	}
+}
+
+func TestGroupGrantCanceledWhileQueuedIsDropped(t *testing.T) {
+	ctx := context.Background()
+	s, gate, groups := newMembershipTest(t)
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := groups.Grant(ctx, testUser(0), testEntitlement)
+		done <- err
+	}()
+	<-gate.entered
+
+	canceled, cancel := context.WithCancel(ctx)
+	go func() {
+		_, err := groups.Grant(canceled, testUser(1), testEntitlement)
+		done <- err
+	}()
+	waitPending(t, groups.membership, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v for the canceled grant, want context.Canceled", err)
+	}
+
+	gate.release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := groupMembers(s), "user-0"; got != want {
+		t.Errorf("got members %s, want %s", got, want)
+	}
+}
+
+func TestGroupGrantRejectsNonUsers(t *testing.T) {
+	groups := groupBuilder(nil, notionScim.NewScimClient("scim-token", nil), nil, nil, 0)
+
+	_, err := groups.Grant(context.Background(), testGroup, testEntitlement)
+	if err == nil {
+		t.Error("got no error granting group membership to a group")
+	}
+}
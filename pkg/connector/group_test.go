@@ -0,0 +1,70 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+)
+
+func countGroupFetches(s *notiontest.Server) int {
+	var n int
+	for _, r := range s.Requests() {
+		if strings.HasPrefix(r, "GET /scim/v2/Groups/") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestGroupPrefetchStopsWhenGrantsDontRun(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	for i := 0; i < 6; i++ {
+		s.AddGroups(notionScim.Group{ID: fmt.Sprintf("group-%d", i), DisplayName: fmt.Sprintf("Group %d", i)})
+	}
+
+	scimClient := notionScim.NewScimClient("scim-token", s.Client())
+	groups := groupBuilder(nil, scimClient, nil, nil, 1)
+	groups.prefetchIdleTimeout = 10 * time.Millisecond
+
+	resources, _, _, err := groups.List(ctx, nil, &pagination.Token{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sync ends without Grants, so the prefetch stops once its window
+	// is full, and drops the groups it holds.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		groups.prefetchMu.Lock()
+		n := len(groups.prefetched)
+		groups.prefetchMu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d prefetched groups kept, want none", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := countGroupFetches(s); n != prefetchWindowPerWorker {
+		t.Errorf("got %d group fetches, want %d", n, prefetchWindowPerWorker)
+	}
+
+	// A late Grants fetches the group itself.
+	s.AddGroups(notionScim.Group{ID: "group-5", DisplayName: "Group 5", Members: []notionScim.Member{{Value: "user-1"}}})
+	grants, _, _, err := groups.Grants(ctx, resources[5], &pagination.Token{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 1 {
+		t.Errorf("got %d grants, want 1", len(grants))
+	}
+}
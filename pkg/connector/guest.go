@@ -0,0 +1,255 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/dstotijn/go-notion"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+)
+
+// guestUsersPage marks the page state that lists the guests found by crawling
+// content, after all workspace members were listed.
+const guestUsersPage = "guest_users"
+
+// userTypeGuest is set as the user type of users that aren't workspace members.
+const userTypeGuest notion.UserType = "guest"
+
+// guestCrawler collects the users referenced by content shared with the
+// integration: page and database authors, People properties and comment
+// authors.
+type guestCrawler struct {
+	client   *notion.Client
+	pageSize int
+	// users holds the users referenced by the current search page, and
+	// listed the guests already returned by earlier pages.
+	users  map[string]notion.User
+	listed map[string]bool
+
+	// commentsRestricted is set once the integration turns out to lack the
+	// Read comments capability, so that comments aren't requested again.
+	commentsRestricted bool
+}
+
+func newGuestCrawler(client *notion.Client, pageSize int) *guestCrawler {
+	return &guestCrawler{
+		client:   client,
+		pageSize: pageSize,
+		users:    make(map[string]notion.User),
+		listed:   make(map[string]bool),
+	}
+}
+
+// add records a referenced user, keeping the most complete copy.
+func (c *guestCrawler) add(user notion.User) {
+	if user.ID == "" {
+		return
+	}
+
+	id := normalizeID(user.ID)
+	if known, ok := c.users[id]; ok && known.Name != "" {
+		return
+	}
+	c.users[id] = user
+}
+
+func (c *guestCrawler) addPage(ctx context.Context, page notion.Page) error {
+	if page.CreatedBy != nil {
+		c.add(notion.User{BaseUser: *page.CreatedBy})
+	}
+	if page.LastEditedBy != nil {
+		c.add(notion.User{BaseUser: *page.LastEditedBy})
+	}
+
+	if props, ok := page.Properties.(notion.DatabasePageProperties); ok {
+		for _, prop := range props {
+			for _, person := range prop.People {
+				c.add(person)
+			}
+			if prop.CreatedBy != nil {
+				c.add(*prop.CreatedBy)
+			}
+			if prop.LastEditedBy != nil {
+				c.add(*prop.LastEditedBy)
+			}
+		}
+	}
+
+	return c.addComments(ctx, page.ID)
+}
+
+func (c *guestCrawler) addComments(ctx context.Context, pageID string) error {
+	if c.commentsRestricted {
+		return nil
+	}
+
+	var cursor string
+	for {
+		resp, err := c.client.FindCommentsByBlockID(ctx, notion.FindCommentsByBlockIDQuery{
+			BlockID:     pageID,
+			StartCursor: cursor,
+			PageSize:    c.pageSize,
+		})
+		if err != nil {
+			var apiErr *notion.APIError
+			if errors.As(err, &apiErr) && apiErr.Code == "restricted_resource" {
+				ctxzap.Extract(ctx).Warn("skipping comment authors, the integration can't read comments")
+				c.commentsRestricted = true
+				return nil
+			}
+			return wrapError(err, "notion-connector: failed to list comments of page %s", pageID)
+		}
+
+		for _, comment := range resp.Results {
+			c.add(notion.User{BaseUser: comment.CreatedBy})
+		}
+
+		if !resp.HasMore || resp.NextCursor == nil {
+			return nil
+		}
+		cursor = *resp.NextCursor
+	}
+}
+
+// crawlPage replaces the collected users with those referenced by the search
+// page at cursor, and returns the cursor of the next page.
+func (c *guestCrawler) crawlPage(ctx context.Context, cursor string) (string, error) {
+	c.users = make(map[string]notion.User)
+
+	return searchContentPage(ctx, c.client, cursor, c.pageSize, func(result interface{}) error {
+		switch r := result.(type) {
+		case notion.Page:
+			return c.addPage(ctx, r)
+		case notion.Database:
+			c.add(notion.User{BaseUser: r.CreatedBy})
+			c.add(notion.User{BaseUser: r.LastEditedBy})
+		}
+		return nil
+	})
+}
+
+// memberIDs returns the IDs of all users the public API lists, which are the
+// members and bots of the workspace.
+func memberIDs(ctx context.Context, client *notion.Client, pageSize int) (map[string]bool, error) {
+	rv := make(map[string]bool)
+	var cursor string
+
+	for {
+		resp, err := client.ListUsers(ctx, &notion.PaginationQuery{PageSize: pageSize, StartCursor: cursor})
+		if err != nil {
+			return nil, wrapError(err, "notion-connector: failed to list users")
+		}
+
+		for _, user := range resp.Results {
+			rv[normalizeID(user.ID)] = true
+		}
+
+		if !resp.HasMore || resp.NextCursor == nil {
+			return rv, nil
+		}
+		cursor = *resp.NextCursor
+	}
+}
+
+// workspaceMemberIDs returns the IDs of the workspace members and bots. They
+// were collected while listing users through the public API, unless the sync
+// was resumed past its first page, in which case they are listed again once.
+func (o *userResourceType) workspaceMemberIDs(ctx context.Context) (map[string]bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.publicIDsComplete {
+		return o.publicIDs, nil
+	}
+
+	ids, err := memberIDs(ctx, o.client, o.pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if o.publicIDs == nil {
+		o.publicIDs = make(map[string]bool)
+	}
+	for id := range ids {
+		o.publicIDs[id] = true
+	}
+	o.publicIDsComplete = true
+
+	return o.publicIDs, nil
+}
+
+// listGuestUsers crawls one search page of the content shared with the
+// integration, and returns the referenced users that aren't workspace members
+// along with the cursor of the next page. SCIM users, keyed by normalized ID,
+// aren't guests either: those the public API doesn't list were synced as SCIM
+// only users. Guests referenced by several pages are returned once per
+// process; after a resumed sync they may be returned again, which is harmless.
+func (o *userResourceType) listGuestUsers(ctx context.Context, cursor string, scimUsers map[string]notionScim.User) ([]*v2.Resource, string, error) {
+	members, err := o.workspaceMemberIDs(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	o.mu.Lock()
+	if o.guestCrawler == nil {
+		o.guestCrawler = newGuestCrawler(o.client, o.pageSize)
+	}
+	crawler := o.guestCrawler
+	o.mu.Unlock()
+
+	next, err := crawler.crawlPage(ctx, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ids := make([]string, 0, len(crawler.users))
+	for id := range crawler.users {
+		if _, inSCIM := scimUsers[id]; !members[id] && !inSCIM && !crawler.listed[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var rv []*v2.Resource
+	for _, id := range ids {
+		user := crawler.users[id]
+		if user.Type == notion.UserTypeBot {
+			continue
+		}
+
+		// Users referenced by ID only are looked up for their name and email.
+		if user.Name == "" {
+			found, err := o.client.FindUserByID(ctx, user.ID)
+			switch {
+			case err == nil:
+				user = found
+			case !isNotFound(err):
+				return nil, "", wrapError(err, "notion-connector: failed to get user %s", user.ID)
+			}
+		}
+		if user.Name == "" {
+			user.Name = fmt.Sprintf("Guest %s", user.ID)
+		}
+		if !o.filter.allowsUser(publicUserEmail(user), false) {
+			continue
+		}
+		user.Type = userTypeGuest
+
+		ur, err := userResource(ctx, &user, nil, o.internalEmailDomains)
+		if err != nil {
+			return nil, "", err
+		}
+		rv = append(rv, ur)
+	}
+
+	// Guests are marked as listed only once the whole page succeeded, so a
+	// retried page returns them again.
+	for _, id := range ids {
+		crawler.listed[id] = true
+	}
+
+	return rv, next, nil
+}
@@ -0,0 +1,78 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/dstotijn/go-notion"
+)
+
+// authoredPage returns a page of the workspace created by the given user.
+func authoredPage(id, authorID string) notion.Page {
+	return notion.Page{
+		ID:           id,
+		Parent:       notion.Parent{Type: notion.ParentTypeWorkspace, Workspace: true},
+		CreatedBy:    &notion.BaseUser{ID: authorID},
+		LastEditedBy: &notion.BaseUser{ID: authorID},
+		Properties:   notion.PageProperties{},
+	}
+}
+
+func TestGuestsLeaveOutSCIMUsers(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+
+	s.AddUsers(notion.User{BaseUser: notion.BaseUser{ID: "user-alice"}, Name: "Alice", Type: notion.UserTypePerson})
+	s.AddSCIMUsers(
+		notionScim.User{ID: "user-alice", UserName: "alice@example.com", Active: true},
+		// A removed employee, whom the public API no longer lists.
+		notionScim.User{ID: "user-dave", UserName: "dave@example.com"},
+	)
+	for _, page := range []notion.Page{authoredPage("page-1", "user-dave"), authoredPage("page-2", "user-guest")} {
+		if err := s.AddPage(page, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Fail(http.MethodGet, "/v1/comments", http.StatusForbidden, "restricted_resource", 0)
+
+	nt, err := New(ctx,
+		WithAPIKey("api-key"),
+		WithSCIMToken("scim-token"),
+		WithHTTPClient(s.Client()),
+		WithRequestsPerSecond(0),
+		WithGuestDiscovery(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := userBuilder(nt.client, nt.scimClient, nil, true, nil, nt.filter, nt.pageSize)
+	var got []string
+	token := &pagination.Token{}
+	for {
+		resources, next, _, err := users.List(ctx, nil, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range resources {
+			got = append(got, r.Id.Resource+" "+r.DisplayName)
+		}
+		if next == "" {
+			break
+		}
+		token = &pagination.Token{Token: next}
+	}
+	sort.Strings(got)
+
+	want := []string{"user-alice Alice", "user-dave dave@example.com", "user-guest Guest user-guest"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got users %q, want %q", got, want)
+	}
+}
@@ -6,7 +6,13 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 )
 
-var resourcePageSize = 50
+const (
+	// defaultPageSize is the number of objects requested per page, unless the
+	// connector is configured otherwise.
+	defaultPageSize = 50
+	// maxPageSize is the largest page size the Notion API accepts.
+	maxPageSize = 100
+)
 
 func parsePageToken(i string, resourceID *v2.ResourceId) (*pagination.Bag, error) {
 	b := &pagination.Bag{}
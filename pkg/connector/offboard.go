@@ -0,0 +1,176 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	offboardStepDone    = "done"
+	offboardStepPlanned = "planned"
+	offboardStepFailed  = "failed"
+)
+
+var offboardUserActionSchema = &v2.BatonActionSchema{
+	Name:        "offboard_user",
+	DisplayName: "Offboard User",
+	Description: "Removes a user from every SCIM group and deactivates the user. With dry_run set, reports what would change without changing it.",
+	Arguments: []*config.Field{
+		stringArgumentField("user_id", "User ID", "The resource ID of the Notion user.", true),
+		{
+			Name:        "dry_run",
+			DisplayName: "Dry Run",
+			Description: "Report the steps that would run without running them.",
+			Field:       &config.Field_BoolField{BoolField: &config.BoolField{}},
+		},
+	},
+	ReturnTypes: []*config.Field{
+		stringListReturnField("steps", "Steps", "The result of every offboarding step, each as a JSON object with its step, status and error, and the group of a group removal."),
+	},
+}
+
+// offboardStep records the outcome of one step of an offboarding.
+func offboardStep(step string, target map[string]interface{}, dryRun bool, err error) map[string]interface{} {
+	rv := map[string]interface{}{
+		"step":   step,
+		"status": offboardStepDone,
+	}
+	for k, v := range target {
+		rv[k] = v
+	}
+
+	switch {
+	case err != nil:
+		rv["status"] = offboardStepFailed
+		rv["error"] = err.Error()
+	case dryRun:
+		rv["status"] = offboardStepPlanned
+	}
+
+	return rv
+}
+
+// groupsOfUser returns the SCIM groups a user is a member of. Only those
+// groups are listed, with a filter on their members. When Notion doesn't
+// support the filter, the groups are read from the state directory if there
+// is one, and otherwise every group is fetched for its members.
+func (nt *Notion) groupsOfUser(ctx context.Context, userID string) ([]notionScim.Group, error) {
+	var groups []notionScim.Group
+	var err error
+	for group, iterErr := range nt.scimClient.FilterGroups(ctx, notionScim.Eq("members.value", userID)) {
+		if iterErr != nil {
+			err = iterErr
+			break
+		}
+		groups = append(groups, group)
+	}
+	switch {
+	case err == nil:
+		return groups, nil
+	case !isUnsupportedFilter(err):
+		return nil, fmt.Errorf("notion-connector: failed to list the groups of user %s: %w", userID, err)
+	}
+	ctxzap.Extract(ctx).Warn("Notion doesn't support filtering groups on members, checking every group", zap.Error(err))
+
+	if nt.scimStore != nil {
+		groups, err = nt.scimStore.refreshGroups(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		groups, err = nt.scimClient.GetPaginatedGroups(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("notion-connector: failed to list groups: %w", err)
+		}
+		for i, g := range groups {
+			groups[i], err = nt.scimClient.GetGroup(ctx, g.ID)
+			if err != nil {
+				return nil, fmt.Errorf("notion-connector: failed to get group %s: %w", g.ID, err)
+			}
+		}
+	}
+
+	var memberOf []notionScim.Group
+	for _, group := range groups {
+		if slices.ContainsFunc(group.Members, func(m notionScim.Member) bool { return sameID(m.Value, userID) }) {
+			memberOf = append(memberOf, group)
+		}
+	}
+	return memberOf, nil
+}
+
+// offboardUser removes a user from all SCIM groups and then deactivates the
+// user. A failed removal doesn't stop the others, but the user is only
+// deactivated when every removal succeeded.
+func (nt *Notion) offboardUser(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	userID, err := stringArgument(args, "user_id")
+	if err != nil {
+		return nil, nil, err
+	}
+	dryRun := args.GetFields()["dry_run"].GetBoolValue()
+
+	memberOf, err := nt.groupsOfUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The removals are sent together, in bulk requests when Notion supports
+	// them.
+	removeErrs := make([]error, len(memberOf))
+	if !dryRun {
+		changes := make([]notionScim.MembershipChange, 0, len(memberOf))
+		for _, group := range memberOf {
+			changes = append(changes, notionScim.MembershipChange{GroupID: group.ID, UserID: userID, Remove: true})
+		}
+		removeErrs = nt.scimClient.ChangeGroupMembers(ctx, changes)
+	}
+
+	var steps []map[string]interface{}
+	failed := false
+	for i, group := range memberOf {
+		failed = failed || removeErrs[i] != nil
+
+		steps = append(steps, offboardStep("remove_group_member", map[string]interface{}{
+			"group_id":   group.ID,
+			"group_name": group.DisplayName,
+		}, dryRun, removeErrs[i]))
+	}
+
+	var deactivateErr error
+	switch {
+	case failed:
+		deactivateErr = fmt.Errorf("notion-connector: not deactivating user %s because a group removal failed", userID)
+	case !dryRun:
+		deactivateErr = nt.scimClient.DeactivateUser(ctx, userID)
+	}
+	failed = failed || deactivateErr != nil
+	steps = append(steps, offboardStep("deactivate_user", nil, dryRun, deactivateErr))
+
+	stepList, err := jsonStrings(steps)
+	if err != nil {
+		return nil, nil, err
+	}
+	rv, err := structpb.NewStruct(map[string]interface{}{
+		"user_id": userID,
+		"dry_run": dryRun,
+		"steps":   stepList,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if failed {
+		return rv, nil, fmt.Errorf("notion-connector: offboarding user %s failed", userID)
+	}
+
+	return rv, nil, nil
+}
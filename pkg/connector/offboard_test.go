@@ -0,0 +1,102 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newOffboardTest starts a fake workspace of groups group-0 to group-3, of
+// which user-1 is a member of group-1 and group-3.
+func newOffboardTest(t *testing.T) (*notiontest.Server, *Notion) {
+	t.Helper()
+
+	s := notiontest.NewServer()
+	t.Cleanup(s.Close)
+	s.AddSCIMUsers(notionScim.User{ID: "user-1", UserName: "user1@example.com", Active: true})
+	for i := 0; i < 4; i++ {
+		group := notionScim.Group{ID: fmt.Sprintf("group-%d", i), DisplayName: fmt.Sprintf("Group %d", i)}
+		if i%2 == 1 {
+			group.Members = []notionScim.Member{{Value: "user-1"}}
+		}
+		s.AddGroups(group)
+	}
+
+	nt, err := New(context.Background(),
+		WithSCIMToken("scim-token"),
+		WithHTTPClient(s.Client()),
+		WithRequestsPerSecond(0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, nt
+}
+
+// offboardSteps runs the offboard_user action for user-1 and returns its
+// steps as "step group status".
+func offboardSteps(t *testing.T, nt *Notion) []string {
+	t.Helper()
+
+	args, err := structpb.NewStruct(map[string]interface{}{"user_id": "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := nt.offboardUser(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []string
+	for _, v := range res.GetFields()["steps"].GetListValue().GetValues() {
+		var step struct {
+			Step    string `json:"step"`
+			GroupID string `json:"group_id"`
+			Status  string `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(v.GetStringValue()), &step); err != nil {
+			t.Fatal(err)
+		}
+		steps = append(steps, strings.TrimSpace(strings.Join([]string{step.Step, step.GroupID, step.Status}, " ")))
+	}
+	return steps
+}
+
+func TestOffboardUserListsOnlyTheirGroups(t *testing.T) {
+	s, nt := newOffboardTest(t)
+
+	got := strings.Join(offboardSteps(t, nt), ", ")
+	want := "remove_group_member group-1 done, remove_group_member group-3 done, deactivate_user  done"
+	if got != want {
+		t.Errorf("got steps %q, want %q", got, want)
+	}
+
+	for _, r := range s.Requests() {
+		if strings.HasPrefix(r, "GET /scim/v2/Groups/") {
+			t.Errorf("got request %s, want the groups listed with a filter only", r)
+		}
+	}
+	for _, id := range []string{"group-1", "group-3"} {
+		if group, _ := s.Group(id); len(group.Members) != 0 {
+			t.Errorf("got members %v of %s, want none", group.Members, id)
+		}
+	}
+}
+
+func TestOffboardUserFallsBackWhenMemberFilterIsUnsupported(t *testing.T) {
+	s, nt := newOffboardTest(t)
+	s.Fail(http.MethodGet, "/scim/v2/Groups", http.StatusBadRequest, "", 1)
+
+	got := strings.Join(offboardSteps(t, nt), ", ")
+	want := "remove_group_member group-1 done, remove_group_member group-3 done, deactivate_user  done"
+	if got != want {
+		t.Errorf("got steps %q, want %q", got, want)
+	}
+}
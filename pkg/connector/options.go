@@ -0,0 +1,196 @@
+package connector
+
+import (
+	"net/http"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"go.uber.org/zap"
+)
+
+// options is the configuration of a connector built by New.
+type options struct {
+	apiKey    string
+	scimToken string
+
+	httpClient        *http.Client
+	apiBaseURL        string
+	scimBaseURL       string
+	logger            *zap.Logger
+	clock             notionScim.Clock
+	requestsPerSecond int
+	pageSize          int
+	scimPageSize      int
+	recordDir         string
+	replayDir         string
+	stateDir          string
+
+	databaseIDs              []string
+	databasePeopleProperties []string
+	ticketDatabaseID         string
+	discoverGuests           bool
+	publicPages              bool
+	internalEmailDomains     []string
+	filters                  Filters
+	groupFetchConcurrency    int
+}
+
+// Option configures a connector built by New.
+type Option func(*options)
+
+// WithAPIKey sets the Notion API key of the integration to sync with.
+func WithAPIKey(apiKey string) Option {
+	return func(o *options) {
+		o.apiKey = apiKey
+	}
+}
+
+// WithSCIMToken sets the SCIM token of the workspace. Without an API key,
+// users and groups are synced through the SCIM API only.
+func WithSCIMToken(scimToken string) Option {
+	return func(o *options) {
+		o.scimToken = scimToken
+	}
+}
+
+// WithHTTPClient sets the HTTP client requests are sent with. The connector
+// wraps its transport, and leaves the client itself unchanged. By default, a
+// client of the baton SDK is used.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithAPIBaseURL sends the requests for the Notion public API to baseURL
+// instead of https://api.notion.com/v1.
+func WithAPIBaseURL(baseURL string) Option {
+	return func(o *options) {
+		o.apiBaseURL = baseURL
+	}
+}
+
+// WithSCIMBaseURL sends the requests for the Notion SCIM API to baseURL
+// instead of https://www.notion.so/scim/v2.
+func WithSCIMBaseURL(baseURL string) Option {
+	return func(o *options) {
+		o.scimBaseURL = baseURL
+	}
+}
+
+// WithLogger sets the logger of the HTTP client the connector creates. By
+// default, the logger of the context passed to New is used. Syncers log to
+// the logger of the context of each call.
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithClock sets the clock the connector tells time and waits by.
+func WithClock(clock notionScim.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithRequestsPerSecond limits the requests sent to Notion, which allows
+// notionScim.DefaultRequestsPerSecond by default. Zero disables the limit.
+func WithRequestsPerSecond(requestsPerSecond int) Option {
+	return func(o *options) {
+		o.requestsPerSecond = requestsPerSecond
+	}
+}
+
+// WithPageSize sets the number of objects requested per page from the Notion
+// public API, at most 100.
+func WithPageSize(pageSize int) Option {
+	return func(o *options) {
+		o.pageSize = pageSize
+	}
+}
+
+// WithSCIMPageSize sets the number of users or groups requested per page from
+// the SCIM API when all of them are listed at once.
+func WithSCIMPageSize(pageSize int) Option {
+	return func(o *options) {
+		o.scimPageSize = pageSize
+	}
+}
+
+// WithRecording writes every request and response to dir, redacted.
+func WithRecording(dir string) Option {
+	return func(o *options) {
+		o.recordDir = dir
+	}
+}
+
+// WithReplay answers requests from the recordings in dir instead of sending
+// them to Notion.
+func WithReplay(dir string) Option {
+	return func(o *options) {
+		o.replayDir = dir
+	}
+}
+
+// WithStateDir keeps the SCIM groups in dir between syncs, so that later
+// syncs request only those modified since the last one.
+func WithStateDir(dir string) Option {
+	return func(o *options) {
+		o.stateDir = dir
+	}
+}
+
+// WithDatabases syncs the rows of the databases with the given IDs, with
+// access granted through the given People properties.
+func WithDatabases(databaseIDs []string, peopleProperties []string) Option {
+	return func(o *options) {
+		o.databaseIDs = databaseIDs
+		o.databasePeopleProperties = peopleProperties
+	}
+}
+
+// WithTicketDatabase creates tickets as pages in the database with the given
+// ID.
+func WithTicketDatabase(databaseID string) Option {
+	return func(o *options) {
+		o.ticketDatabaseID = databaseID
+	}
+}
+
+// WithGuestDiscovery enables crawling the content shared with the integration
+// for guests.
+func WithGuestDiscovery(enabled bool) Option {
+	return func(o *options) {
+		o.discoverGuests = enabled
+	}
+}
+
+// WithPublicPages enables syncing the pages published to the web.
+func WithPublicPages(enabled bool) Option {
+	return func(o *options) {
+		o.publicPages = enabled
+	}
+}
+
+// WithInternalEmailDomains classifies users with an email address outside of
+// domains as external.
+func WithInternalEmailDomains(domains ...string) Option {
+	return func(o *options) {
+		o.internalEmailDomains = domains
+	}
+}
+
+// WithFilters skips the users and groups that don't pass filters.
+func WithFilters(filters Filters) Option {
+	return func(o *options) {
+		o.filters = filters
+	}
+}
+
+// WithGroupFetchConcurrency prefetches group details with the given number of
+// workers. Zero disables prefetching.
+func WithGroupFetchConcurrency(concurrency int) Option {
+	return func(o *options) {
+		o.groupFetchConcurrency = concurrency
+	}
+}
@@ -0,0 +1,170 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	grant "github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	publicAccessEntitlement = "public_access"
+
+	// anyoneID is the ID of the synthetic principal that stands for everyone
+	// who can open a public URL.
+	anyoneID = "anyone"
+)
+
+type publicPageResourceType struct {
+	resourceType *v2.ResourceType
+	apiClient    *notionScim.APIClient
+	pageSize     int
+}
+
+func (p *publicPageResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return p.resourceType
+}
+
+// Create a new connector resource for a Notion page published to the web.
+func publicPageResource(page notionScim.SharedPage) (*v2.Resource, error) {
+	name := pageTitle(page.Page)
+	if name == "" {
+		name = page.ID
+	}
+
+	risk, err := structpb.NewStruct(map[string]interface{}{
+		"risk":       publicAccessEntitlement,
+		"public_url": *page.PublicURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := rs.NewResource(
+		name,
+		resourceTypePage,
+		page.ID,
+		rs.WithAnnotation(
+			&v2.ExternalLink{Url: page.URL},
+			risk,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// List returns the pages shared with the integration that are published to
+// the web. Other pages are skipped.
+func (p *publicPageResourceType) List(ctx context.Context, _ *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	var pageToken string
+	bag, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypePage.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	pagesResponse, err := p.apiClient.SearchPages(ctx, bag.PageToken(), p.pageSize)
+	if err != nil {
+		return nil, "", nil, wrapError(err, "notion-connector: failed to search pages")
+	}
+
+	if pagesResponse.HasMore && pagesResponse.NextCursor != nil {
+		pageToken, err = bag.NextToken(*pagesResponse.NextCursor)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+
+	var rv []*v2.Resource
+	for _, page := range pagesResponse.Results {
+		if page.PublicURL == nil || *page.PublicURL == "" {
+			continue
+		}
+
+		pr, err := publicPageResource(page)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, pr)
+	}
+
+	return rv, pageToken, nil, nil
+}
+
+func (p *publicPageResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	assigmentOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(resourceTypePublic),
+		ent.WithDescription(fmt.Sprintf("Can open %s through its public URL", resource.DisplayName)),
+		ent.WithDisplayName(fmt.Sprintf("%s public access", resource.DisplayName)),
+	}
+
+	return []*v2.Entitlement{
+		ent.NewAssignmentEntitlement(resource, publicAccessEntitlement, assigmentOptions...),
+	}, "", nil, nil
+}
+
+// Grants grants public access to anyone on the internet. Only pages published
+// to the web are listed, so every page has this grant.
+func (p *publicPageResourceType) Grants(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	principalID, err := rs.NewResourceID(resourceTypePublic, anyoneID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return []*v2.Grant{
+		grant.NewGrant(resource, publicAccessEntitlement, principalID),
+	}, "", nil, nil
+}
+
+func publicPageBuilder(apiClient *notionScim.APIClient, pageSize int) *publicPageResourceType {
+	return &publicPageResourceType{
+		resourceType: resourceTypePage,
+		apiClient:    apiClient,
+		pageSize:     pageSize,
+	}
+}
+
+type publicResourceType struct {
+	resourceType *v2.ResourceType
+}
+
+func (p *publicResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return p.resourceType
+}
+
+// List returns the synthetic "Anyone on the internet" principal.
+func (p *publicResourceType) List(_ context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	anyone, err := rs.NewResource(
+		"Anyone on the internet",
+		resourceTypePublic,
+		anyoneID,
+	)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return []*v2.Resource{anyone}, "", nil, nil
+}
+
+func (p *publicResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (p *publicResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func publicBuilder() *publicResourceType {
+	return &publicResourceType{
+		resourceType: resourceTypePublic,
+	}
+}
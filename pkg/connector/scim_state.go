@@ -0,0 +1,356 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// scimStateFile is the file of the state directory the SCIM groups are kept
+// in between syncs.
+const scimStateFile = "scim-state.json"
+
+// lastModifiedOverlap is how long before the last sync changes are requested
+// from, so that none is missed when the clocks of the connector and Notion
+// disagree. Resources changed in the overlap are merged again, to no effect.
+const lastModifiedOverlap = 5 * time.Minute
+
+// scimState is what the state file holds: the SCIM groups as of the last
+// sync.
+type scimState struct {
+	Groups scimResources[notionScim.Group] `json:"groups"`
+}
+
+// scimResources are SCIM resources, and the time they were listed at.
+type scimResources[T any] struct {
+	SyncedAt  time.Time `json:"synced_at"`
+	Resources []T       `json:"resources"`
+}
+
+// scimStore keeps the SCIM groups in a state directory between syncs. After
+// the first sync, only the groups modified since the last one are requested,
+// and merged with those kept. Groups are kept with their members, so that the
+// groups that didn't change aren't requested at all.
+//
+// Users are listed in full once per process instead. Deleted resources don't
+// show up as modified, and finding them takes listing the IDs of all
+// resources, which for users costs as many requests as listing them.
+//
+// The lock is only held to read and replace the state, so that group lookups
+// aren't blocked while a refresh waits on Notion.
+type scimStore struct {
+	client      *notionScim.ScimClient
+	clock       notionScim.Clock
+	path        string
+	concurrency int
+
+	mu         sync.Mutex
+	loaded     bool
+	scimUsers  []notionScim.User
+	state      scimState
+	groupIndex map[string]int
+}
+
+func newSCIMStore(client *notionScim.ScimClient, clock notionScim.Clock, dir string, concurrency int) *scimStore {
+	return &scimStore{
+		client:      client,
+		clock:       clock,
+		path:        filepath.Join(dir, scimStateFile),
+		concurrency: concurrency,
+	}
+}
+
+// users returns the SCIM users, listed again when refresh is set or they
+// weren't yet since the connector started.
+func (s *scimStore) users(ctx context.Context, refresh bool) ([]notionScim.User, error) {
+	s.mu.Lock()
+	users := s.scimUsers
+	s.mu.Unlock()
+	if !refresh && users != nil {
+		return users, nil
+	}
+
+	users, err := s.listUsers(ctx, notionScim.Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("notion-connector: failed to list users: %w", err)
+	}
+	if users == nil {
+		users = []notionScim.User{}
+	}
+
+	s.mu.Lock()
+	s.scimUsers = users
+	s.mu.Unlock()
+	return users, nil
+}
+
+// refreshGroups brings the SCIM groups up to date and returns them, with
+// their members. The groups are refreshed from a copy of the state, which
+// replaces it once done.
+func (s *scimStore) refreshGroups(ctx context.Context) ([]notionScim.Group, error) {
+	s.mu.Lock()
+	s.load(ctx)
+	groups := s.state.Groups
+	s.mu.Unlock()
+
+	err := refreshResources(ctx, s.clock, &groups,
+		func(group notionScim.Group) string { return group.ID },
+		s.listGroups,
+		func() iter.Seq2[string, error] { return s.client.GroupIDs(ctx) },
+	)
+	if err != nil {
+		return nil, fmt.Errorf("notion-connector: failed to list groups: %w", err)
+	}
+
+	groupIndex := make(map[string]int, len(groups.Resources))
+	for i, group := range groups.Resources {
+		groupIndex[group.ID] = i
+	}
+
+	s.mu.Lock()
+	s.state.Groups = groups
+	s.groupIndex = groupIndex
+	state := s.state
+	s.mu.Unlock()
+
+	if err := s.save(state); err != nil {
+		return nil, err
+	}
+	return groups.Resources, nil
+}
+
+// group returns a group as of the last refresh of the groups.
+func (s *scimStore) group(groupID string) (notionScim.Group, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.groupIndex[groupID]
+	if !ok {
+		return notionScim.Group{}, false
+	}
+	return s.state.Groups.Resources[i], true
+}
+
+func (s *scimStore) listUsers(ctx context.Context, filter notionScim.Filter) ([]notionScim.User, error) {
+	var users []notionScim.User
+	for user, err := range s.client.FilterUsers(ctx, filter) {
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// listGroups lists the groups matching filter, and fetches the details of
+// each, which hold their members, with s.concurrency workers.
+func (s *scimStore) listGroups(ctx context.Context, filter notionScim.Filter) ([]notionScim.Group, error) {
+	var groups []notionScim.Group
+	for group, err := range s.client.FilterGroups(ctx, filter) {
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := make(chan int, len(groups))
+	for i := range groups {
+		queue <- i
+	}
+	close(queue)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for w := 0; w < max(s.concurrency, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				group, err := s.client.GetGroup(ctx, groups[i].ID)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+				groups[i] = group
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return groups, nil
+}
+
+// load reads the state file, once. Without a readable state file, the next
+// refreshes list all resources. Callers must hold s.mu.
+func (s *scimStore) load(ctx context.Context) {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &s.state)
+	}
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("ignoring unreadable SCIM state, running a full sync",
+			zap.String("path", s.path),
+			zap.Error(err),
+		)
+		s.state = scimState{}
+	}
+}
+
+// save writes state to the state file. It is replaced at once, so that a sync
+// that is interrupted leaves the state of the previous one.
+func (s *scimStore) save(state scimState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("notion-connector: failed to create state directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, scimStateFile+".*")
+	if err != nil {
+		return fmt.Errorf("notion-connector: failed to write SCIM state: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		return fmt.Errorf("notion-connector: failed to write SCIM state: %w", err)
+	}
+	return nil
+}
+
+// refreshResources brings r up to date. After a first sync, only the
+// resources modified since the last one are listed, and merged with r by ID.
+// Deleted resources don't show up as modified, so the IDs of all resources
+// are listed too, and those kept that aren't among them are dropped. All
+// resources are listed again when Notion doesn't support filtering on
+// meta.lastModified, or when a resource is missing from the merge.
+func refreshResources[T any](
+	ctx context.Context,
+	clock notionScim.Clock,
+	r *scimResources[T],
+	id func(T) string,
+	list func(context.Context, notionScim.Filter) ([]T, error),
+	ids func() iter.Seq2[string, error],
+) error {
+	l := ctxzap.Extract(ctx)
+	now := clock.Now()
+
+	if !r.SyncedAt.IsZero() {
+		since := r.SyncedAt.Add(-lastModifiedOverlap).UTC().Format(time.RFC3339)
+		changed, err := list(ctx, notionScim.Gt("meta.lastModified", since))
+		switch {
+		case err == nil:
+			current := make(map[string]bool)
+			for resourceID, err := range ids() {
+				if err != nil {
+					return err
+				}
+				current[resourceID] = true
+			}
+
+			merged := mergeResources(r.Resources, changed, id)
+			kept := make([]T, 0, len(current))
+			for _, resource := range merged {
+				if current[id(resource)] {
+					kept = append(kept, resource)
+				}
+			}
+			if len(kept) == len(current) {
+				l.Debug("synced SCIM resources modified since the last sync",
+					zap.Time("since", r.SyncedAt),
+					zap.Int("modified", len(changed)),
+					zap.Int("deleted", len(merged)-len(kept)),
+				)
+				r.SyncedAt, r.Resources = now, kept
+				return nil
+			}
+			l.Info("SCIM resources are missing from those modified since the last sync, running a full sync",
+				zap.Int("total", len(current)),
+				zap.Int("kept", len(kept)),
+			)
+		case isUnsupportedFilter(err):
+			l.Warn("Notion doesn't support filtering on lastModified, running a full sync", zap.Error(err))
+		default:
+			return err
+		}
+	}
+
+	resources, err := list(ctx, notionScim.Filter{})
+	if err != nil {
+		return err
+	}
+	r.SyncedAt, r.Resources = now, resources
+	return nil
+}
+
+// mergeResources replaces the resources that changed in place, and appends
+// the new ones.
+func mergeResources[T any](resources []T, changed []T, id func(T) string) []T {
+	merged := append([]T(nil), resources...)
+	index := make(map[string]int, len(merged))
+	for i, resource := range merged {
+		index[id(resource)] = i
+	}
+
+	for _, resource := range changed {
+		if i, ok := index[id(resource)]; ok {
+			merged[i] = resource
+			continue
+		}
+		index[id(resource)] = len(merged)
+		merged = append(merged, resource)
+	}
+	return merged
+}
+
+// isUnsupportedFilter reports whether err is the response of a SCIM server
+// that doesn't support a filter.
+func isUnsupportedFilter(err error) bool {
+	var scimErr *notionScim.SCIMError
+	if !errors.As(err, &scimErr) {
+		return false
+	}
+	return scimErr.StatusCode == http.StatusBadRequest || scimErr.StatusCode == http.StatusNotImplemented
+}
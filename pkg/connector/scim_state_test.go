@@ -0,0 +1,312 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// fixedClock is a Clock that stays at now, which tests move forward.
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fixedClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.now.Add(d)
+	return ch
+}
+
+type scimStoreTest struct {
+	t      *testing.T
+	server *notiontest.Server
+	client *notionScim.ScimClient
+	clock  *fixedClock
+	dir    string
+}
+
+// newSCIMStoreTest starts a fake workspace of users user-0 to user-2 and
+// groups group-0 and group-1, last modified an hour before the clock.
+func newSCIMStoreTest(t *testing.T) *scimStoreTest {
+	t.Helper()
+
+	s := notiontest.NewServer()
+	t.Cleanup(s.Close)
+
+	clock := &fixedClock{now: time.Date(2024, 5, 13, 12, 0, 0, 0, time.UTC)}
+	created := &notionScim.Meta{LastModified: clock.now.Add(-time.Hour)}
+	for i := 0; i < 3; i++ {
+		s.AddSCIMUsers(notionScim.User{
+			ID:       fmt.Sprintf("user-%d", i),
+			UserName: fmt.Sprintf("user%d@example.com", i),
+			Active:   true,
+			Meta:     created,
+		})
+	}
+	for i := 0; i < 2; i++ {
+		s.AddGroups(notionScim.Group{
+			ID:          fmt.Sprintf("group-%d", i),
+			DisplayName: fmt.Sprintf("Group %d", i),
+			Members:     []notionScim.Member{{Value: fmt.Sprintf("user-%d", i)}},
+			Meta:        created,
+		})
+	}
+
+	return &scimStoreTest{
+		t:      t,
+		server: s,
+		client: notionScim.NewScimClient("scim-token", s.Client()),
+		clock:  clock,
+		dir:    t.TempDir(),
+	}
+}
+
+// groups runs a sync of the groups with a new store, as a new run of the
+// connector would, and returns their IDs and members, along with the
+// requests it sent.
+func (st *scimStoreTest) groups() ([]string, []string) {
+	st.t.Helper()
+
+	before := len(st.server.Requests())
+	store := newSCIMStore(st.client, st.clock, st.dir, 1)
+	groups, err := store.refreshGroups(context.Background())
+	if err != nil {
+		st.t.Fatal(err)
+	}
+
+	var rv []string
+	for _, group := range groups {
+		members := make([]string, 0, len(group.Members))
+		for _, member := range group.Members {
+			members = append(members, member.Value)
+		}
+		rv = append(rv, strings.TrimSpace(group.ID+" "+strings.Join(members, " ")))
+	}
+	sort.Strings(rv)
+	return rv, st.server.Requests()[before:]
+}
+
+// later moves the clock forward, and returns the metadata of a resource
+// modified in between.
+func (st *scimStoreTest) later() *notionScim.Meta {
+	st.clock.now = st.clock.now.Add(time.Hour)
+	return &notionScim.Meta{LastModified: st.clock.now.Add(-time.Minute)}
+}
+
+func checkGroups(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got groups %q, want %q", got, want)
+	}
+}
+
+func checkRequests(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+}
+
+func TestSCIMStoreFirstSyncIsFull(t *testing.T) {
+	st := newSCIMStoreTest(t)
+
+	groups, requests := st.groups()
+	checkGroups(t, groups, "group-0 user-0", "group-1 user-1")
+	checkRequests(t, requests, "GET /scim/v2/Groups", "GET /scim/v2/Groups/group-0", "GET /scim/v2/Groups/group-1")
+
+	b, err := os.ReadFile(filepath.Join(st.dir, scimStateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state scimState
+	if err := json.Unmarshal(b, &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Groups.Resources) != 2 || !state.Groups.SyncedAt.Equal(st.clock.now) {
+		t.Errorf("got %d groups synced at %s in the state file, want 2 at %s", len(state.Groups.Resources), state.Groups.SyncedAt, st.clock.now)
+	}
+}
+
+func TestSCIMStoreMergesModifiedGroups(t *testing.T) {
+	st := newSCIMStoreTest(t)
+	st.groups()
+
+	meta := st.later()
+	st.server.AddGroups(
+		notionScim.Group{ID: "group-1", DisplayName: "Group 1", Members: []notionScim.Member{{Value: "user-1"}, {Value: "user-2"}}, Meta: meta},
+		notionScim.Group{ID: "group-2", DisplayName: "Group 2", Meta: meta},
+	)
+
+	// The modified groups and the IDs of all of them are listed, and only
+	// the modified groups are fetched.
+	groups, requests := st.groups()
+	checkGroups(t, groups, "group-0 user-0", "group-1 user-1 user-2", "group-2")
+	checkRequests(t, requests, "GET /scim/v2/Groups", "GET /scim/v2/Groups/group-1", "GET /scim/v2/Groups/group-2", "GET /scim/v2/Groups")
+}
+
+func TestSCIMStoreDropsDeletedGroups(t *testing.T) {
+	st := newSCIMStoreTest(t)
+	st.groups()
+
+	st.later()
+	if err := st.client.DeleteGroup(context.Background(), "group-0"); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, requests := st.groups()
+	checkGroups(t, groups, "group-1 user-1")
+	checkRequests(t, requests, "GET /scim/v2/Groups", "GET /scim/v2/Groups")
+}
+
+func TestSCIMStoreFallsBackWhenFilterIsUnsupported(t *testing.T) {
+	st := newSCIMStoreTest(t)
+	st.groups()
+
+	// The change isn't marked as modified, so only a full sync finds it.
+	st.later()
+	st.server.AddGroups(notionScim.Group{ID: "group-1", DisplayName: "Group 1"})
+	st.server.Fail(http.MethodGet, "/scim/v2/Groups", http.StatusBadRequest, "", 1)
+
+	groups, requests := st.groups()
+	checkGroups(t, groups, "group-0 user-0", "group-1")
+	checkRequests(t, requests, "GET /scim/v2/Groups", "GET /scim/v2/Groups", "GET /scim/v2/Groups/group-0", "GET /scim/v2/Groups/group-1")
+}
+
+func TestSCIMStoreFallsBackWhenGroupIsMissing(t *testing.T) {
+	st := newSCIMStoreTest(t)
+	st.groups()
+
+	// A group that doesn't show up as modified, yet isn't kept either.
+	st.later()
+	st.server.AddGroups(notionScim.Group{ID: "group-2", DisplayName: "Group 2"})
+
+	groups, requests := st.groups()
+	checkGroups(t, groups, "group-0 user-0", "group-1 user-1", "group-2")
+	checkRequests(t, requests,
+		"GET /scim/v2/Groups", "GET /scim/v2/Groups",
+		"GET /scim/v2/Groups", "GET /scim/v2/Groups/group-0", "GET /scim/v2/Groups/group-1", "GET /scim/v2/Groups/group-2",
+	)
+}
+
+func TestSCIMStoreIgnoresCorruptState(t *testing.T) {
+	st := newSCIMStoreTest(t)
+	path := filepath.Join(st.dir, scimStateFile)
+	if err := os.WriteFile(path, []byte(`{"groups": {"synced_at": `), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, requests := st.groups()
+	checkGroups(t, groups, "group-0 user-0", "group-1 user-1")
+	checkRequests(t, requests, "GET /scim/v2/Groups", "GET /scim/v2/Groups/group-0", "GET /scim/v2/Groups/group-1")
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &scimState{}); err != nil {
+		t.Errorf("got an unreadable state file after the sync: %v", err)
+	}
+}
+
+func TestSCIMStoreListsUsersInFull(t *testing.T) {
+	ctx := context.Background()
+	st := newSCIMStoreTest(t)
+	store := newSCIMStore(st.client, st.clock, st.dir, 1)
+
+	users, err := store.users(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 {
+		t.Fatalf("got %d users, want 3", len(users))
+	}
+
+	// Without refresh, the users listed before are returned; with it, all
+	// users are listed again, with no lastModified filter.
+	before := len(st.server.Requests())
+	if _, err := store.users(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	checkRequests(t, st.server.Requests()[before:])
+
+	st.server.AddSCIMUsers(notionScim.User{ID: "user-3", UserName: "user3@example.com"})
+	users, err = store.users(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 4 {
+		t.Errorf("got %d users after a refresh, want 4", len(users))
+	}
+	checkRequests(t, st.server.Requests()[before:], "GET /scim/v2/Users")
+	if _, err := os.Stat(filepath.Join(st.dir, scimStateFile)); err == nil {
+		t.Error("got a state file written for users")
+	}
+}
+
+func TestSCIMStoreGroupLookupsDontWaitForRefresh(t *testing.T) {
+	ctx := context.Background()
+	st := newSCIMStoreTest(t)
+	store := newSCIMStore(st.client, st.clock, st.dir, 1)
+	if _, err := store.refreshGroups(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next refresh is held up in Notion, while lookups still see the
+	// groups of the last one.
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	base := st.server.Client().Transport
+	store.client = notionScim.NewScimClient("scim-token", &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		once.Do(func() { close(entered) })
+		<-release
+		return base.RoundTrip(req)
+	})})
+
+	done := make(chan error)
+	go func() {
+		_, err := store.refreshGroups(ctx)
+		done <- err
+	}()
+	<-entered
+
+	lookup := make(chan bool)
+	go func() {
+		_, ok := store.group("group-1")
+		lookup <- ok
+	}()
+	select {
+	case ok := <-lookup:
+		if !ok {
+			t.Error("got no group-1 during a refresh")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("group lookup blocked by a refresh")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
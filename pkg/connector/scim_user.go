@@ -0,0 +1,120 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+)
+
+// scimUserResourceType syncs users through the SCIM API. It is used instead of
+// userResourceType when the connector runs without a Notion API key.
+type scimUserResourceType struct {
+	resourceType *v2.ResourceType
+	scimClient   *notionScim.ScimClient
+	// scimStore, when set, keeps the SCIM users between syncs.
+	scimStore *scimStore
+
+	internalEmailDomains []string
+	filter               *filter
+	pageSize             int
+}
+
+func (o *scimUserResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+func (o *scimUserResourceType) List(ctx context.Context, _ *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	var pageToken string
+	bag, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeUser.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// SCIM pages by a 1-based start index, which is kept as the page token.
+	startIndex := 1
+	if bag.PageToken() != "" {
+		startIndex, err = strconv.Atoi(bag.PageToken())
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("notion-connector: invalid page token %q: %w", bag.PageToken(), err)
+		}
+	}
+
+	usersResponse, err := o.listUsers(ctx, startIndex, token.Token == "")
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	nextIndex := startIndex + len(usersResponse.Resources)
+	if len(usersResponse.Resources) > 0 && int64(nextIndex) <= usersResponse.TotalResults {
+		pageToken, err = bag.NextToken(strconv.Itoa(nextIndex))
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+
+	var rv []*v2.Resource
+	for _, user := range usersResponse.Resources {
+		if !o.filter.allowsUser(user.PrimaryEmail(), false) {
+			continue
+		}
+
+		userCopy := user
+		ur, err := userResource(ctx, nil, &userCopy, o.internalEmailDomains)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, ur)
+	}
+
+	return rv, pageToken, nil, nil
+}
+
+// listUsers returns the page of users at startIndex. With a store, the page
+// is taken from the stored users, which the first page of a sync refreshes.
+func (o *scimUserResourceType) listUsers(ctx context.Context, startIndex int, firstPage bool) (notionScim.UsersResponse, error) {
+	if o.scimStore == nil {
+		resp, err := o.scimClient.GetUsers(ctx, o.pageSize, startIndex, notionScim.Filter{})
+		if err != nil {
+			return notionScim.UsersResponse{}, fmt.Errorf("notion-connector: failed to list users: %w", err)
+		}
+		return resp, nil
+	}
+
+	users, err := o.scimStore.users(ctx, firstPage)
+	if err != nil {
+		return notionScim.UsersResponse{}, err
+	}
+
+	start := min(max(startIndex-1, 0), len(users))
+	end := min(start+o.pageSize, len(users))
+	return notionScim.UsersResponse{
+		TotalResults: int64(len(users)),
+		StartIndex:   int64(startIndex),
+		ItemsPerPage: int64(end - start),
+		Resources:    users[start:end],
+	}, nil
+}
+
+func (o *scimUserResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (o *scimUserResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func scimUserBuilder(scimClient *notionScim.ScimClient, scimStore *scimStore, internalEmailDomains []string, filter *filter, pageSize int) *scimUserResourceType {
+	return &scimUserResourceType{
+		resourceType:         resourceTypeUser,
+		scimClient:           scimClient,
+		scimStore:            scimStore,
+		internalEmailDomains: internalEmailDomains,
+		filter:               filter,
+		pageSize:             pageSize,
+	}
+}
@@ -0,0 +1,422 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/dotc1z"
+	"github.com/conductorone/baton-sdk/pkg/sync"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/dstotijn/go-notion"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// groupCount is more than one page of SCIM groups, so that paging is covered.
+const groupCount = 102
+
+// newFixture starts a fake Notion workspace with members, a bot, a user known
+// only to SCIM and more groups than fit on one SCIM page.
+func newFixture(t testing.TB) *notiontest.Server {
+	t.Helper()
+
+	s := notiontest.NewServer()
+	t.Cleanup(s.Close)
+
+	s.AddUsers(
+		notion.User{
+			BaseUser:  notion.BaseUser{ID: "user-alice"},
+			Type:      notion.UserTypePerson,
+			Name:      "Alice Anderson",
+			AvatarURL: "https://example.com/alice.png",
+			Person:    &notion.Person{Email: "alice@example.com"},
+		},
+		notion.User{
+			BaseUser: notion.BaseUser{ID: "user-bob"},
+			Type:     notion.UserTypePerson,
+			Name:     "Bob Brown",
+			Person:   &notion.Person{Email: "bob@contractor.example.org"},
+		},
+		notion.User{
+			BaseUser: notion.BaseUser{ID: "user-bot"},
+			Type:     notion.UserTypeBot,
+			Name:     "Integration",
+			Bot:      &notion.Bot{},
+		},
+	)
+
+	s.AddSCIMUsers(
+		notionScim.User{
+			ID:       "user-alice",
+			UserName: "alice@example.com",
+			Name:     notionScim.Name{Formatted: "Alice Anderson", GivenName: "Alice", FamilyName: "Anderson"},
+			Title:    "Engineer",
+			Emails:   []notionScim.Email{{Value: "alice@example.com", Primary: true}, {Value: "alice.anderson@example.com"}},
+			Active:   true,
+			Enterprise: &notionScim.EnterpriseUser{
+				EmployeeNumber: "1001",
+				Department:     "Engineering",
+			},
+		},
+		notionScim.User{
+			ID:       "user-bob",
+			UserName: "bob@contractor.example.org",
+			Name:     notionScim.Name{Formatted: "Bob Brown", GivenName: "Bob", FamilyName: "Brown"},
+			Emails:   []notionScim.Email{{Value: "bob@contractor.example.org", Primary: true}},
+			Active:   false,
+		},
+		notionScim.User{
+			ID:       "user-carol",
+			UserName: "carol@example.com",
+			Name:     notionScim.Name{Formatted: "Carol Clark", GivenName: "Carol", FamilyName: "Clark"},
+			Emails:   []notionScim.Email{{Value: "carol@example.com", Primary: true}},
+			Active:   true,
+		},
+	)
+
+	for i := 0; i < groupCount; i++ {
+		group := notionScim.Group{
+			ID:          fmt.Sprintf("group-%03d", i),
+			DisplayName: fmt.Sprintf("Group %03d", i),
+		}
+		switch i {
+		case 0:
+			group.Members = []notionScim.Member{{Value: "user-alice"}, {Value: "user-bob"}}
+		case groupCount - 1:
+			group.Members = []notionScim.Member{{Value: "user-alice"}, {Value: "user-carol"}}
+		}
+		s.AddGroups(group)
+	}
+
+	return s
+}
+
+// newTestConnector returns a connector that talks to the fake server.
+func newTestConnector(ctx context.Context, t testing.TB, s *notiontest.Server) *Notion {
+	t.Helper()
+
+	nt, err := New(ctx,
+		WithAPIKey("api-key"),
+		WithSCIMToken("scim-token"),
+		WithHTTPClient(s.Client()),
+		WithRequestsPerSecond(0),
+		WithInternalEmailDomains("example.com"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return nt
+}
+
+type connectorClient struct {
+	v2.ResourceTypesServiceClient
+	v2.ResourcesServiceClient
+	v2.EntitlementsServiceClient
+	v2.GrantsServiceClient
+	v2.ConnectorServiceClient
+	v2.AssetServiceClient
+	v2.GrantManagerServiceClient
+	v2.ResourceManagerServiceClient
+	v2.ResourceDeleterServiceClient
+	v2.AccountManagerServiceClient
+	v2.CredentialManagerServiceClient
+	v2.EventServiceClient
+	v2.TicketsServiceClient
+	v2.ActionServiceClient
+	v2.ResourceGetterServiceClient
+}
+
+// serveConnector serves a connector over gRPC on a local port, the way the
+// connector runner does, and returns a client for it.
+func serveConnector(t *testing.T, srv types.ConnectorServer) types.ConnectorClient {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := grpc.NewServer()
+	v2.RegisterResourceTypesServiceServer(s, srv)
+	v2.RegisterResourcesServiceServer(s, srv)
+	v2.RegisterEntitlementsServiceServer(s, srv)
+	v2.RegisterGrantsServiceServer(s, srv)
+	v2.RegisterConnectorServiceServer(s, srv)
+	v2.RegisterAssetServiceServer(s, srv)
+	v2.RegisterGrantManagerServiceServer(s, srv)
+	v2.RegisterResourceManagerServiceServer(s, srv)
+	v2.RegisterResourceDeleterServiceServer(s, srv)
+	v2.RegisterAccountManagerServiceServer(s, srv)
+	v2.RegisterCredentialManagerServiceServer(s, srv)
+	v2.RegisterEventServiceServer(s, srv)
+	v2.RegisterTicketsServiceServer(s, srv)
+	v2.RegisterActionServiceServer(s, srv)
+	v2.RegisterResourceGetterServiceServer(s, srv)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &connectorClient{
+		ResourceTypesServiceClient:     v2.NewResourceTypesServiceClient(conn),
+		ResourcesServiceClient:         v2.NewResourcesServiceClient(conn),
+		EntitlementsServiceClient:      v2.NewEntitlementsServiceClient(conn),
+		GrantsServiceClient:            v2.NewGrantsServiceClient(conn),
+		ConnectorServiceClient:         v2.NewConnectorServiceClient(conn),
+		AssetServiceClient:             v2.NewAssetServiceClient(conn),
+		GrantManagerServiceClient:      v2.NewGrantManagerServiceClient(conn),
+		ResourceManagerServiceClient:   v2.NewResourceManagerServiceClient(conn),
+		ResourceDeleterServiceClient:   v2.NewResourceDeleterServiceClient(conn),
+		AccountManagerServiceClient:    v2.NewAccountManagerServiceClient(conn),
+		CredentialManagerServiceClient: v2.NewCredentialManagerServiceClient(conn),
+		EventServiceClient:             v2.NewEventServiceClient(conn),
+		TicketsServiceClient:           v2.NewTicketsServiceClient(conn),
+		ActionServiceClient:            v2.NewActionServiceClient(conn),
+		ResourceGetterServiceClient:    v2.NewResourceGetterServiceClient(conn),
+	}
+}
+
+// syncToC1Z runs a full sync of the connector into a new c1z file.
+func syncToC1Z(ctx context.Context, t *testing.T, nt *Notion) string {
+	t.Helper()
+
+	srv, err := connectorbuilder.NewConnector(ctx, nt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sync.c1z")
+	syncer, err := sync.NewSyncer(ctx, serveConnector(t, srv), sync.WithC1ZPath(path), sync.WithTmpDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+type goldenUser struct {
+	Status      string                 `json:"status"`
+	AccountType string                 `json:"account_type"`
+	Emails      []string               `json:"emails"`
+	Profile     map[string]interface{} `json:"profile"`
+}
+
+type goldenResource struct {
+	ResourceType string      `json:"resource_type"`
+	ID           string      `json:"id"`
+	DisplayName  string      `json:"display_name"`
+	Parent       string      `json:"parent,omitempty"`
+	User         *goldenUser `json:"user,omitempty"`
+}
+
+type goldenEntitlement struct {
+	ID          string `json:"id"`
+	Resource    string `json:"resource"`
+	Slug        string `json:"slug"`
+	DisplayName string `json:"display_name"`
+}
+
+type goldenGrant struct {
+	Entitlement string `json:"entitlement"`
+	Principal   string `json:"principal"`
+}
+
+// goldenSync is the part of a sync compared against the golden file.
+// Resources keep the order in which they were listed within their type, so
+// that paging bugs that reorder them show up.
+type goldenSync struct {
+	Resources    []goldenResource    `json:"resources"`
+	Entitlements []goldenEntitlement `json:"entitlements"`
+	Grants       []goldenGrant       `json:"grants"`
+}
+
+func resourceKey(id *v2.ResourceId) string {
+	if id == nil {
+		return ""
+	}
+	return id.ResourceType + ":" + id.Resource
+}
+
+func (g *goldenSync) addResource(t *testing.T, r *v2.Resource) {
+	t.Helper()
+
+	gr := goldenResource{
+		ResourceType: r.Id.ResourceType,
+		ID:           r.Id.Resource,
+		DisplayName:  r.DisplayName,
+		Parent:       resourceKey(r.ParentResourceId),
+	}
+
+	if r.Id.ResourceType == resourceTypeUser.Id {
+		ut, err := rs.GetUserTrait(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gu := &goldenUser{
+			Status:      ut.GetStatus().GetStatus().String(),
+			AccountType: ut.GetAccountType().String(),
+			Profile:     ut.GetProfile().AsMap(),
+		}
+		for _, email := range ut.GetEmails() {
+			gu.Emails = append(gu.Emails, email.GetAddress())
+		}
+		gr.User = gu
+	}
+
+	g.Resources = append(g.Resources, gr)
+}
+
+func (g *goldenSync) addEntitlement(e *v2.Entitlement) {
+	g.Entitlements = append(g.Entitlements, goldenEntitlement{
+		ID:          e.Id,
+		Resource:    resourceKey(e.Resource.GetId()),
+		Slug:        e.Slug,
+		DisplayName: e.DisplayName,
+	})
+}
+
+func (g *goldenSync) addGrant(gr *v2.Grant) {
+	g.Grants = append(g.Grants, goldenGrant{
+		Entitlement: gr.Entitlement.GetId(),
+		Principal:   resourceKey(gr.Principal.GetId()),
+	})
+}
+
+// sort orders resources by type, keeping the listing order within a type,
+// and entitlements and grants by ID.
+func (g *goldenSync) sort() {
+	sort.SliceStable(g.Resources, func(i, j int) bool {
+		return g.Resources[i].ResourceType < g.Resources[j].ResourceType
+	})
+	sort.Slice(g.Entitlements, func(i, j int) bool {
+		return g.Entitlements[i].ID < g.Entitlements[j].ID
+	})
+	sort.Slice(g.Grants, func(i, j int) bool {
+		if g.Grants[i].Entitlement != g.Grants[j].Entitlement {
+			return g.Grants[i].Entitlement < g.Grants[j].Entitlement
+		}
+		return g.Grants[i].Principal < g.Grants[j].Principal
+	})
+}
+
+// readC1Z reads the resources, entitlements and grants of the last sync in a
+// c1z file.
+func readC1Z(ctx context.Context, t *testing.T, path string) *goldenSync {
+	t.Helper()
+
+	f, err := dotc1z.NewC1ZFile(ctx, path, dotc1z.WithTmpDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	g := &goldenSync{}
+
+	var pageToken string
+	for {
+		resp, err := f.ListResources(ctx, &v2.ResourcesServiceListResourcesRequest{PageToken: pageToken})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range resp.List {
+			g.addResource(t, r)
+		}
+		if pageToken = resp.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+
+	for {
+		resp, err := f.ListEntitlements(ctx, &v2.EntitlementsServiceListEntitlementsRequest{PageToken: pageToken})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range resp.List {
+			g.addEntitlement(e)
+		}
+		if pageToken = resp.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+
+	for {
+		resp, err := f.ListGrants(ctx, &v2.GrantsServiceListGrantsRequest{PageToken: pageToken})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, gr := range resp.List {
+			g.addGrant(gr)
+		}
+		if pageToken = resp.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+
+	g.sort()
+	return g
+}
+
+// assertGolden compares got with the golden file, or rewrites the file when
+// the tests run with -update.
+func assertGolden(t *testing.T, name string, got interface{}) {
+	t.Helper()
+
+	b, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, '\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(want) {
+		t.Errorf("%s differs from the sync, run the tests with -update if the change is intended.\ngot:\n%s", path, b)
+	}
+}
+
+func TestSyncGolden(t *testing.T) {
+	ctx := context.Background()
+	s := newFixture(t)
+	nt := newTestConnector(ctx, t, s)
+
+	path := syncToC1Z(ctx, t, nt)
+	assertGolden(t, "sync.golden.json", readC1Z(ctx, t, path))
+}
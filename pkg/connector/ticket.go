@@ -0,0 +1,327 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkTicket "github.com/conductorone/baton-sdk/pkg/types/ticket"
+	"github.com/dstotijn/go-notion"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Notion limits a single rich text object to 2000 characters.
+const richTextMaxLength = 2000
+
+var errTicketDatabaseNotConfigured = errors.New("notion-connector: ticket database is not configured")
+
+// richText splits s into rich text objects no longer than Notion allows.
+func richText(s string) []notion.RichText {
+	var rv []notion.RichText
+	runes := []rune(s)
+	for len(runes) > 0 {
+		n := min(len(runes), richTextMaxLength)
+		rv = append(rv, notion.RichText{Text: &notion.Text{Content: string(runes[:n])}})
+		runes = runes[n:]
+	}
+	return rv
+}
+
+// sameID reports whether two Notion IDs are equal, ignoring dashes.
+func sameID(a, b string) bool {
+	return normalizeID(a) == normalizeID(b)
+}
+
+// normalizeID strips the dashes Notion may or may not include in an ID.
+func normalizeID(id string) string {
+	return strings.ReplaceAll(id, "-", "")
+}
+
+// propertyNameByType returns the name of the first database property of the given type.
+func propertyNameByType(props notion.DatabaseProperties, propType notion.DatabasePropertyType) string {
+	for name, prop := range props {
+		if prop.Type == propType {
+			return name
+		}
+	}
+	return ""
+}
+
+// ticketSchema builds a ticket schema from the ticket database. The schema ID
+// is the database ID and the custom fields are the database properties, and
+// the comments of the ticket page.
+func ticketSchema(db notion.Database) *v2.TicketSchema {
+	name := plainText(db.Title)
+	if name == "" {
+		name = db.ID
+	}
+
+	var statuses []*v2.TicketStatus
+	if statusProp := propertyNameByType(db.Properties, notion.DBPropTypeStatus); statusProp != "" {
+		if status := db.Properties[statusProp].Status; status != nil {
+			for _, option := range status.Options {
+				statuses = append(statuses, &v2.TicketStatus{
+					Id:          option.ID,
+					DisplayName: option.Name,
+				})
+			}
+		}
+	}
+
+	customFields := customFieldSchemas(db.Properties)
+	customFields[ticketCommentsField] = sdkTicket.StringsFieldSchema(ticketCommentsField, "Comments", false)
+
+	return &v2.TicketSchema{
+		Id:           db.ID,
+		DisplayName:  name,
+		Statuses:     statuses,
+		CustomFields: customFields,
+	}
+}
+
+// ticketFromPage converts a page of the ticket database into a ticket. The
+// status is read from the page's Status property.
+func ticketFromPage(page notion.Page) *v2.Ticket {
+	ticket := &v2.Ticket{
+		Id:           page.ID,
+		DisplayName:  page.ID,
+		Url:          page.URL,
+		CreatedAt:    timestamppb.New(page.CreatedTime),
+		UpdatedAt:    timestamppb.New(page.LastEditedTime),
+		CustomFields: make(map[string]*v2.TicketCustomField),
+	}
+
+	props, ok := page.Properties.(notion.DatabasePageProperties)
+	if !ok {
+		return ticket
+	}
+
+	for _, prop := range props {
+		switch prop.Type {
+		case notion.DBPropTypeTitle:
+			if title := plainText(prop.Title); title != "" {
+				ticket.DisplayName = title
+			}
+		case notion.DBPropTypeStatus:
+			if prop.Status != nil {
+				ticket.Status = &v2.TicketStatus{
+					Id:          prop.Status.ID,
+					DisplayName: prop.Status.Name,
+				}
+			}
+		default:
+		}
+
+		if cf := customFieldValue(prop); cf != nil {
+			ticket.CustomFields[prop.ID] = cf
+		}
+	}
+
+	return ticket
+}
+
+func (nt *Notion) ticketDatabase(ctx context.Context) (notion.Database, error) {
+	if nt.ticketDatabaseID == "" {
+		return notion.Database{}, errTicketDatabaseNotConfigured
+	}
+
+	db, err := nt.client.FindDatabaseByID(ctx, nt.ticketDatabaseID)
+	if err != nil {
+		return notion.Database{}, wrapError(err, "notion-connector: failed to get ticket database")
+	}
+
+	return db, nil
+}
+
+// ticketPage returns the page with the given ID if it belongs to the ticket database.
+func (nt *Notion) ticketPage(ctx context.Context, ticketID string) (notion.Page, error) {
+	if nt.ticketDatabaseID == "" {
+		return notion.Page{}, errTicketDatabaseNotConfigured
+	}
+
+	page, err := nt.client.FindPageByID(ctx, ticketID)
+	if err != nil {
+		return notion.Page{}, wrapError(err, "notion-connector: failed to get ticket %s", ticketID)
+	}
+
+	if !sameID(page.Parent.DatabaseID, nt.ticketDatabaseID) {
+		return notion.Page{}, fmt.Errorf("notion-connector: page %s is not in the ticket database", ticketID)
+	}
+
+	return page, nil
+}
+
+// GetTicket returns the ticket stored in the page with the given ID, along
+// with the comment thread of the page.
+func (nt *Notion) GetTicket(ctx context.Context, ticketID string) (*v2.Ticket, annotations.Annotations, error) {
+	page, err := nt.ticketPage(ctx, ticketID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ticket := ticketFromPage(page)
+
+	comments, err := nt.ticketComments(ctx, page.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(comments) > 0 {
+		ticket.CustomFields[ticketCommentsField] = sdkTicket.StringsField(ticketCommentsField, comments)
+	}
+
+	return ticket, nil, nil
+}
+
+// CreateTicket creates a page in the ticket database. The ticket description
+// becomes the page content and custom fields are set as page properties.
+func (nt *Notion) CreateTicket(ctx context.Context, ticket *v2.Ticket, schema *v2.TicketSchema) (*v2.Ticket, annotations.Annotations, error) {
+	db, err := nt.ticketDatabase(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if schema == nil {
+		schema = ticketSchema(db)
+	}
+
+	valid, err := sdkTicket.ValidateTicket(ctx, schema, ticket)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !valid {
+		return nil, nil, sdkTicket.ErrTicketValidationError
+	}
+
+	titleProp := propertyNameByType(db.Properties, notion.DBPropTypeTitle)
+	if titleProp == "" {
+		return nil, nil, fmt.Errorf("notion-connector: ticket database %s has no title property", db.ID)
+	}
+
+	props := notion.DatabasePageProperties{
+		titleProp: notion.DatabasePageProperty{
+			Title: richText(ticket.GetDisplayName()),
+		},
+	}
+
+	if status := ticket.GetStatus(); status != nil {
+		statusProp := propertyNameByType(db.Properties, notion.DBPropTypeStatus)
+		if statusProp == "" {
+			return nil, nil, fmt.Errorf("notion-connector: ticket database %s has no status property", db.ID)
+		}
+		props[statusProp] = notion.DatabasePageProperty{
+			Status: &notion.SelectOptions{ID: status.GetId()},
+		}
+	}
+
+	propsByID := make(map[string]notion.DatabaseProperty, len(db.Properties))
+	for name, prop := range db.Properties {
+		prop.Name = name
+		propsByID[prop.ID] = prop
+	}
+
+	for id, cf := range ticket.GetCustomFields() {
+		if id == ticketCommentsField {
+			continue
+		}
+
+		prop, ok := propsByID[id]
+		if !ok {
+			return nil, nil, fmt.Errorf("notion-connector: ticket database %s has no property with ID %s", db.ID, id)
+		}
+
+		value, err := pagePropertyFromCustomField(prop, cf)
+		if err != nil {
+			return nil, nil, err
+		}
+		props[prop.Name] = value
+	}
+
+	var children []notion.Block
+	if description := ticket.GetDescription(); description != "" {
+		children = append(children, notion.ParagraphBlock{RichText: richText(description)})
+	}
+
+	page, err := nt.client.CreatePage(ctx, notion.CreatePageParams{
+		ParentType:             notion.ParentTypeDatabase,
+		ParentID:               db.ID,
+		DatabasePageProperties: &props,
+		Children:               children,
+	})
+	if err != nil {
+		return nil, nil, wrapError(err, "notion-connector: failed to create ticket")
+	}
+
+	return ticketFromPage(page), nil, nil
+}
+
+// GetTicketSchema returns the schema of the ticket database.
+func (nt *Notion) GetTicketSchema(ctx context.Context, schemaID string) (*v2.TicketSchema, annotations.Annotations, error) {
+	if !sameID(schemaID, nt.ticketDatabaseID) {
+		return nil, nil, fmt.Errorf("notion-connector: unknown ticket schema %s", schemaID)
+	}
+
+	db, err := nt.ticketDatabase(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ticketSchema(db), nil, nil
+}
+
+// ListTicketSchemas returns the schema of the ticket database, which is the only one.
+func (nt *Notion) ListTicketSchemas(ctx context.Context, _ *pagination.Token) ([]*v2.TicketSchema, string, annotations.Annotations, error) {
+	db, err := nt.ticketDatabase(ctx)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return []*v2.TicketSchema{ticketSchema(db)}, "", nil, nil
+}
+
+func (nt *Notion) BulkCreateTickets(ctx context.Context, request *v2.TicketsServiceBulkCreateTicketsRequest) (*v2.TicketsServiceBulkCreateTicketsResponse, error) {
+	var tickets []*v2.TicketsServiceCreateTicketResponse
+	for _, ticketReq := range request.GetTicketRequests() {
+		reqBody := ticketReq.GetRequest()
+		ticketBody := &v2.Ticket{
+			DisplayName:  reqBody.GetDisplayName(),
+			Description:  reqBody.GetDescription(),
+			Status:       reqBody.GetStatus(),
+			Labels:       reqBody.GetLabels(),
+			CustomFields: reqBody.GetCustomFields(),
+			RequestedFor: reqBody.GetRequestedFor(),
+		}
+
+		ticket, annos, err := nt.CreateTicket(ctx, ticketBody, ticketReq.GetSchema())
+		resp := &v2.TicketsServiceCreateTicketResponse{
+			Ticket:      ticket,
+			Annotations: annos,
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		tickets = append(tickets, resp)
+	}
+
+	return &v2.TicketsServiceBulkCreateTicketsResponse{Tickets: tickets}, nil
+}
+
+func (nt *Notion) BulkGetTickets(ctx context.Context, request *v2.TicketsServiceBulkGetTicketsRequest) (*v2.TicketsServiceBulkGetTicketsResponse, error) {
+	var tickets []*v2.TicketsServiceGetTicketResponse
+	for _, ticketReq := range request.GetTicketRequests() {
+		ticket, annos, err := nt.GetTicket(ctx, ticketReq.GetId())
+		resp := &v2.TicketsServiceGetTicketResponse{
+			Ticket:      ticket,
+			Annotations: annos,
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		tickets = append(tickets, resp)
+	}
+
+	return &v2.TicketsServiceBulkGetTicketsResponse{Tickets: tickets}, nil
+}
@@ -0,0 +1,96 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/dstotijn/go-notion"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ticketCommentsField is the custom field that carries the comment thread of a
+// ticket page. It is read-only: comments are posted with the
+// add_ticket_comment action, and the field is ignored when creating tickets.
+const ticketCommentsField = "comments"
+
+var addTicketCommentActionSchema = &v2.BatonActionSchema{
+	Name:        "add_ticket_comment",
+	DisplayName: "Add Ticket Comment",
+	Description: "Posts a comment on the Notion page that backs a ticket.",
+	Arguments: []*config.Field{
+		stringArgumentField("ticket_id", "Ticket ID", "The ID of the ticket.", true),
+		stringArgumentField("comment", "Comment", "The text of the comment.", true),
+	},
+	ReturnTypes: []*config.Field{
+		stringArgumentField("comment_id", "Comment ID", "The ID of the created comment.", true),
+	},
+}
+
+// formatComment renders a comment as a single line for the audit record.
+func formatComment(comment notion.Comment) string {
+	return fmt.Sprintf("%s %s: %s", comment.CreatedTime.Format(time.RFC3339), comment.CreatedBy.ID, plainText(comment.RichText))
+}
+
+// ticketComments returns the unresolved comments on a ticket page, oldest first.
+func (nt *Notion) ticketComments(ctx context.Context, pageID string) ([]string, error) {
+	var rv []string
+	var cursor string
+
+	for {
+		resp, err := nt.client.FindCommentsByBlockID(ctx, notion.FindCommentsByBlockIDQuery{
+			BlockID:     pageID,
+			StartCursor: cursor,
+			PageSize:    nt.pageSize,
+		})
+		if err != nil {
+			return nil, wrapError(err, "notion-connector: failed to list comments of ticket %s", pageID)
+		}
+
+		for _, comment := range resp.Results {
+			rv = append(rv, formatComment(comment))
+		}
+
+		if !resp.HasMore || resp.NextCursor == nil {
+			break
+		}
+		cursor = *resp.NextCursor
+	}
+
+	return rv, nil
+}
+
+// addTicketComment posts a ticket update as a comment on the ticket page.
+func (nt *Notion) addTicketComment(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	ticketID, err := stringArgument(args, "ticket_id")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	text, err := stringArgument(args, "comment")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Make sure the page is a ticket before commenting on it.
+	if _, err := nt.ticketPage(ctx, ticketID); err != nil {
+		return nil, nil, err
+	}
+
+	comment, err := nt.client.CreateComment(ctx, notion.CreateCommentParams{
+		ParentPageID: ticketID,
+		RichText:     richText(text),
+	})
+	if err != nil {
+		return nil, nil, wrapError(err, "notion-connector: failed to comment on ticket %s", ticketID)
+	}
+
+	return &structpb.Struct{
+		Fields: map[string]*structpb.Value{
+			"comment_id": structpb.NewStringValue(comment.ID),
+		},
+	}, nil, nil
+}
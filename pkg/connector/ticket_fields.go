@@ -0,0 +1,206 @@
+package connector
+
+import (
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	sdkTicket "github.com/conductorone/baton-sdk/pkg/types/ticket"
+	"github.com/dstotijn/go-notion"
+)
+
+// Ticket custom fields are derived from the properties of the ticket database
+// and keyed by property ID, so renaming a property in Notion does not break
+// tickets that are already in flight. The title property is the ticket display
+// name and is not exposed as a custom field.
+
+func selectObjectValue(option notion.SelectOptions) *v2.TicketCustomFieldObjectValue {
+	return &v2.TicketCustomFieldObjectValue{
+		Id:          option.ID,
+		DisplayName: option.Name,
+	}
+}
+
+func selectObjectValues(options []notion.SelectOptions) []*v2.TicketCustomFieldObjectValue {
+	var rv []*v2.TicketCustomFieldObjectValue
+	for _, option := range options {
+		rv = append(rv, selectObjectValue(option))
+	}
+	return rv
+}
+
+// customFieldSchema maps a database property to a ticket custom field schema.
+// It returns nil for property types that can't be set on a ticket.
+func customFieldSchema(prop notion.DatabaseProperty) *v2.TicketCustomField {
+	switch prop.Type {
+	case notion.DBPropTypeRichText, notion.DBPropTypeURL, notion.DBPropTypeEmail, notion.DBPropTypePhoneNumber:
+		return sdkTicket.StringFieldSchema(prop.ID, prop.Name, false)
+	case notion.DBPropTypeNumber:
+		return sdkTicket.NumberFieldSchema(prop.ID, prop.Name, false)
+	case notion.DBPropTypeCheckbox:
+		return sdkTicket.BoolFieldSchema(prop.ID, prop.Name, false)
+	case notion.DBPropTypeDate:
+		return sdkTicket.TimestampFieldSchema(prop.ID, prop.Name, false)
+	case notion.DBPropTypeSelect:
+		var options []notion.SelectOptions
+		if prop.Select != nil {
+			options = prop.Select.Options
+		}
+		return sdkTicket.PickObjectValueFieldSchema(prop.ID, prop.Name, false, selectObjectValues(options))
+	case notion.DBPropTypeStatus:
+		var options []notion.SelectOptions
+		if prop.Status != nil {
+			options = prop.Status.Options
+		}
+		return sdkTicket.PickObjectValueFieldSchema(prop.ID, prop.Name, false, selectObjectValues(options))
+	case notion.DBPropTypeMultiSelect:
+		var options []notion.SelectOptions
+		if prop.MultiSelect != nil {
+			options = prop.MultiSelect.Options
+		}
+		return sdkTicket.PickMultipleObjectValuesFieldSchema(prop.ID, prop.Name, false, selectObjectValues(options))
+	case notion.DBPropTypePeople:
+		// People are set by Notion user ID.
+		return sdkTicket.StringsFieldSchema(prop.ID, prop.Name, false)
+	default:
+		return nil
+	}
+}
+
+// customFieldSchemas maps the properties of a database to ticket custom field schemas.
+func customFieldSchemas(props notion.DatabaseProperties) map[string]*v2.TicketCustomField {
+	rv := make(map[string]*v2.TicketCustomField)
+	for _, prop := range props {
+		if cf := customFieldSchema(prop); cf != nil {
+			rv[prop.ID] = cf
+		}
+	}
+	return rv
+}
+
+// customFieldValue maps the value of a page property to a ticket custom field.
+// It returns nil for property types that aren't exposed as custom fields.
+func customFieldValue(prop notion.DatabasePageProperty) *v2.TicketCustomField {
+	switch prop.Type {
+	case notion.DBPropTypeRichText:
+		return sdkTicket.StringField(prop.ID, plainText(prop.RichText))
+	case notion.DBPropTypeURL:
+		return sdkTicket.StringField(prop.ID, derefString(prop.URL))
+	case notion.DBPropTypeEmail:
+		return sdkTicket.StringField(prop.ID, derefString(prop.Email))
+	case notion.DBPropTypePhoneNumber:
+		return sdkTicket.StringField(prop.ID, derefString(prop.PhoneNumber))
+	case notion.DBPropTypeNumber:
+		if prop.Number == nil {
+			return nil
+		}
+		return sdkTicket.NumberField(prop.ID, float32(*prop.Number))
+	case notion.DBPropTypeCheckbox:
+		return sdkTicket.BoolField(prop.ID, prop.Checkbox != nil && *prop.Checkbox)
+	case notion.DBPropTypeDate:
+		if prop.Date == nil {
+			return nil
+		}
+		return sdkTicket.TimestampField(prop.ID, prop.Date.Start.Time)
+	case notion.DBPropTypeSelect:
+		if prop.Select == nil {
+			return nil
+		}
+		return sdkTicket.PickObjectValueField(prop.ID, selectObjectValue(*prop.Select))
+	case notion.DBPropTypeStatus:
+		if prop.Status == nil {
+			return nil
+		}
+		return sdkTicket.PickObjectValueField(prop.ID, selectObjectValue(*prop.Status))
+	case notion.DBPropTypeMultiSelect:
+		return sdkTicket.PickMultipleObjectValuesField(prop.ID, selectObjectValues(prop.MultiSelect))
+	case notion.DBPropTypePeople:
+		var ids []string
+		for _, person := range prop.People {
+			ids = append(ids, person.ID)
+		}
+		return sdkTicket.StringsField(prop.ID, ids)
+	default:
+		return nil
+	}
+}
+
+// pagePropertyFromCustomField converts a ticket custom field into the value of
+// a database property, for creating a page.
+func pagePropertyFromCustomField(prop notion.DatabaseProperty, cf *v2.TicketCustomField) (notion.DatabasePageProperty, error) {
+	var rv notion.DatabasePageProperty
+
+	switch prop.Type {
+	case notion.DBPropTypeRichText, notion.DBPropTypeURL, notion.DBPropTypeEmail, notion.DBPropTypePhoneNumber:
+		v, err := sdkTicket.GetStringValue(cf)
+		if err != nil {
+			return rv, err
+		}
+		switch prop.Type {
+		case notion.DBPropTypeURL:
+			rv.URL = &v
+		case notion.DBPropTypeEmail:
+			rv.Email = &v
+		case notion.DBPropTypePhoneNumber:
+			rv.PhoneNumber = &v
+		default:
+			rv.RichText = richText(v)
+		}
+	case notion.DBPropTypeNumber:
+		v, err := sdkTicket.GetNumberValue(cf)
+		if err != nil {
+			return rv, err
+		}
+		n := float64(v)
+		rv.Number = &n
+	case notion.DBPropTypeCheckbox:
+		v, err := sdkTicket.GetBoolValue(cf)
+		if err != nil {
+			return rv, err
+		}
+		rv.Checkbox = &v
+	case notion.DBPropTypeDate:
+		v, err := sdkTicket.GetTimestampValue(cf)
+		if err != nil {
+			return rv, err
+		}
+		rv.Date = &notion.Date{Start: notion.NewDateTime(v, true)}
+	case notion.DBPropTypeSelect, notion.DBPropTypeStatus:
+		v, err := sdkTicket.GetPickObjectValue(cf)
+		if err != nil {
+			return rv, err
+		}
+		option := &notion.SelectOptions{ID: v.GetId()}
+		if prop.Type == notion.DBPropTypeStatus {
+			rv.Status = option
+		} else {
+			rv.Select = option
+		}
+	case notion.DBPropTypeMultiSelect:
+		v, err := sdkTicket.GetPickMultipleObjectValues(cf)
+		if err != nil {
+			return rv, err
+		}
+		for _, ov := range v {
+			rv.MultiSelect = append(rv.MultiSelect, notion.SelectOptions{ID: ov.GetId()})
+		}
+	case notion.DBPropTypePeople:
+		v, err := sdkTicket.GetStringsValue(cf)
+		if err != nil {
+			return rv, err
+		}
+		for _, id := range v {
+			rv.People = append(rv.People, notion.User{BaseUser: notion.BaseUser{ID: id}})
+		}
+	default:
+		return rv, fmt.Errorf("notion-connector: property %s of type %s can't be set on a ticket", prop.Name, prop.Type)
+	}
+
+	return rv, nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -2,60 +2,211 @@ package connector
 
 import (
 	"context"
-	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/dstotijn/go-notion"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// scimOnlyUsersPage marks the page state that lists the SCIM users the public
+// API doesn't return, after all public API users were listed.
+const scimOnlyUsersPage = "scim_only_users"
+
+const (
+	userClassificationInternal = "internal"
+	userClassificationExternal = "external"
 )
 
 type userResourceType struct {
 	resourceType *v2.ResourceType
 	client       *notion.Client
+	scimClient   *notionScim.ScimClient
+	// scimStore, when set, keeps the SCIM users between syncs.
+	scimStore *scimStore
+
+	// discoverGuests enables crawling content for users that aren't
+	// workspace members.
+	discoverGuests bool
+
+	internalEmailDomains []string
+	filter               *filter
+	pageSize             int
+
+	// SCIM users by ID, and the IDs of the users listed through the public
+	// API, kept for the duration of a sync to join both views and to tell
+	// guests from members. publicIDsComplete is set once publicIDs holds
+	// every user of the public API.
+	mu                 sync.Mutex
+	scimUsers          map[string]notionScim.User
+	publicIDs          map[string]bool
+	publicIDsFromStart bool
+	publicIDsComplete  bool
+	guestCrawler       *guestCrawler
 }
 
 func (o *userResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return o.resourceType
 }
 
-// Create a new connector resource for a Notion user.
-func userResource(ctx context.Context, user notion.User) (*v2.Resource, error) {
-	names := strings.SplitN(user.Name, " ", 2)
-	var firstName, lastName, email string
+// userSourceAnnotation records on which side a user was found when the public
+// API and SCIM views of the workspace disagree.
+func userSourceAnnotation(foundInPublicAPI, foundInSCIM bool) (*structpb.Struct, error) {
+	return structpb.NewStruct(map[string]interface{}{
+		"found_in_public_api": foundInPublicAPI,
+		"found_in_scim":       foundInSCIM,
+	})
+}
 
-	switch len(names) {
-	case 1:
-		firstName = names[0]
-	case 2:
-		firstName = names[0]
-		lastName = names[1]
+// userClassification tells internal from external users by the domain of
+// their email address. Subdomains of an internal domain are internal too.
+func userClassification(email string, internalEmailDomains []string) string {
+	if emailInDomains(email, internalEmailDomains) {
+		return userClassificationInternal
 	}
+	return userClassificationExternal
+}
 
-	if user.Person != nil {
-		email = user.Person.Email
+// Create a new connector resource for a Notion user. Either the public API
+// user or the SCIM user may be nil; when both are given their attributes are
+// merged. With internalEmailDomains set, people are classified as internal or
+// external.
+func userResource(ctx context.Context, user *notion.User, scimUser *notionScim.User, internalEmailDomains []string, opts ...rs.ResourceOption) (*v2.Resource, error) {
+	var id, name, firstName, lastName, email string
+	profile := map[string]interface{}{}
+	accountType := v2.UserTrait_ACCOUNT_TYPE_HUMAN
+	status := v2.UserTrait_Status_STATUS_ENABLED
+
+	if user != nil {
+		id = user.ID
+		name = user.Name
+
+		names := strings.SplitN(user.Name, " ", 2)
+		switch len(names) {
+		case 1:
+			firstName = names[0]
+		case 2:
+			firstName = names[0]
+			lastName = names[1]
+		}
+
+		if user.Person != nil {
+			email = user.Person.Email
+		}
+		if user.Type == notion.UserTypeBot {
+			accountType = v2.UserTrait_ACCOUNT_TYPE_SERVICE
+		}
+
+		profile["user_type"] = string(user.Type)
+		if user.AvatarURL != "" {
+			profile["avatar_url"] = user.AvatarURL
+		}
 	}
 
-	profile := map[string]interface{}{
-		"first_name": firstName,
-		"last_name":  lastName,
-		"login":      email,
-		"user_id":    user.ID,
+	emails := []string{}
+	if email != "" {
+		emails = append(emails, email)
 	}
 
+	var employeeIDs []string
+	if scimUser != nil {
+		// Grants reference users by their public API ID, which SCIM may
+		// format differently, so it is kept when there is one.
+		if user == nil {
+			id = scimUser.ID
+		}
+		if name == "" {
+			name = scimUser.Name.Formatted
+		}
+		if scimUser.Name.GivenName != "" || scimUser.Name.FamilyName != "" {
+			firstName = scimUser.Name.GivenName
+			lastName = scimUser.Name.FamilyName
+		}
+		if email == "" {
+			email = scimUser.PrimaryEmail()
+			emails = append(emails, email)
+		}
+		for _, e := range scimUser.Emails {
+			if !containsFold(emails, e.Value) {
+				emails = append(emails, e.Value)
+			}
+		}
+		if !scimUser.Active {
+			status = v2.UserTrait_Status_STATUS_DISABLED
+		}
+
+		profile["scim_active"] = scimUser.Active
+		if _, ok := profile["avatar_url"]; !ok && scimUser.Photo() != "" {
+			profile["avatar_url"] = scimUser.Photo()
+		}
+		if scimUser.Title != "" {
+			profile["title"] = scimUser.Title
+		}
+		if ent := scimUser.Enterprise; ent != nil {
+			for k, v := range map[string]string{
+				"employee_number": ent.EmployeeNumber,
+				"cost_center":     ent.CostCenter,
+				"organization":    ent.Organization,
+				"division":        ent.Division,
+				"department":      ent.Department,
+			} {
+				if v != "" {
+					profile[k] = v
+				}
+			}
+			if ent.Manager != nil && ent.Manager.Value != "" {
+				profile["manager_id"] = ent.Manager.Value
+			}
+			if ent.EmployeeNumber != "" {
+				employeeIDs = append(employeeIDs, ent.EmployeeNumber)
+			}
+		}
+	}
+
+	if name == "" {
+		name = email
+	}
+
+	// External users keep the human account type: the SDK only has human,
+	// service and system accounts, and the last two would hide contractors
+	// among bots. The classification in the profile is all that tells them
+	// apart.
+	if len(internalEmailDomains) > 0 && accountType == v2.UserTrait_ACCOUNT_TYPE_HUMAN {
+		profile["classification"] = userClassification(email, internalEmailDomains)
+	}
+
+	profile["first_name"] = firstName
+	profile["last_name"] = lastName
+	profile["login"] = email
+	profile["user_id"] = id
+
 	userTraitOptions := []rs.UserTraitOption{
 		rs.WithUserProfile(profile),
 		rs.WithEmail(email, true),
-		rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+		rs.WithStatus(status),
+		rs.WithAccountType(accountType),
+	}
+	for _, e := range emails {
+		if e != email {
+			userTraitOptions = append(userTraitOptions, rs.WithEmail(e, false))
+		}
+	}
+	if len(employeeIDs) > 0 {
+		userTraitOptions = append(userTraitOptions, rs.WithEmployeeID(employeeIDs...))
 	}
 
 	ret, err := rs.NewUserResource(
-		user.Name,
+		name,
 		resourceTypeUser,
-		user.ID,
+		id,
 		userTraitOptions,
+		opts...,
 	)
 	if err != nil {
 		return nil, err
@@ -64,6 +215,69 @@ func userResource(ctx context.Context, user notion.User) (*v2.Resource, error) {
 	return ret, nil
 }
 
+// publicUserEmail returns the email address of a person, or an empty string
+// for bots.
+func publicUserEmail(user notion.User) string {
+	if user.Person == nil {
+		return ""
+	}
+	return user.Person.Email
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// resetSync drops the state kept from a previous sync. It's called on the
+// first page of a sync.
+func (o *userResourceType) resetSync() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.scimUsers = nil
+	o.publicIDs = make(map[string]bool)
+	o.publicIDsFromStart = true
+	o.publicIDsComplete = false
+	o.guestCrawler = nil
+}
+
+// loadSCIMUsers fetches all SCIM users once per sync. The first page of a sync
+// passes reset to refresh the users of a previous sync.
+func (o *userResourceType) loadSCIMUsers(ctx context.Context, reset bool) (map[string]notionScim.User, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.scimUsers != nil {
+		return o.scimUsers, nil
+	}
+
+	scimUsers := make(map[string]notionScim.User)
+	if o.scimStore != nil {
+		users, err := o.scimStore.users(ctx, reset)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			scimUsers[normalizeID(user.ID)] = user
+		}
+	} else {
+		for user, err := range o.scimClient.Users(ctx) {
+			if err != nil {
+				return nil, err
+			}
+			scimUsers[normalizeID(user.ID)] = user
+		}
+	}
+	o.scimUsers = scimUsers
+
+	return o.scimUsers, nil
+}
+
 func (o *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	var pageToken string
 	bag, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeUser.Id})
@@ -71,21 +285,109 @@ func (o *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, to
 		return nil, "", nil, err
 	}
 
-	usersResponse, err := o.client.ListUsers(ctx, &notion.PaginationQuery{PageSize: resourcePageSize, StartCursor: bag.PageToken()})
-	if err != nil {
-		return nil, "", nil, fmt.Errorf("notion-connector: failed to list users: %w", err)
+	if token.Token == "" {
+		o.resetSync()
 	}
 
-	if usersResponse.HasMore {
-		pageToken, err = bag.NextToken(*usersResponse.NextCursor)
+	var scimUsers map[string]notionScim.User
+	if o.scimClient != nil {
+		scimUsers, err = o.loadSCIMUsers(ctx, token.Token == "")
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+
+	switch bag.ResourceTypeID() {
+	case scimOnlyUsersPage:
+		rv, err := o.listSCIMOnlyUsers(ctx, scimUsers)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		pageToken, err = nextUsersPageToken(bag, o.nextUsersPage(scimOnlyUsersPage))
+		if err != nil {
+			return nil, "", nil, err
+		}
+		return rv, pageToken, nil, nil
+	case guestUsersPage:
+		rv, next, err := o.listGuestUsers(ctx, bag.PageToken(), scimUsers)
 		if err != nil {
 			return nil, "", nil, err
 		}
+		if next != "" {
+			pageToken, err = bag.NextToken(next)
+			if err != nil {
+				return nil, "", nil, err
+			}
+		}
+		return rv, pageToken, nil, nil
+	}
+
+	usersResponse, err := o.client.ListUsers(ctx, &notion.PaginationQuery{PageSize: o.pageSize, StartCursor: bag.PageToken()})
+	if err != nil {
+		return nil, "", nil, wrapError(err, "notion-connector: failed to list users")
 	}
 
+	if usersResponse.HasMore {
+		pageToken, err = bag.NextToken(*usersResponse.NextCursor)
+	} else {
+		pageToken, err = nextUsersPageToken(bag, o.nextUsersPage(resourceTypeUser.Id))
+	}
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	o.mu.Lock()
+	if o.publicIDs == nil {
+		o.publicIDs = make(map[string]bool)
+	}
+	for _, user := range usersResponse.Results {
+		o.publicIDs[normalizeID(user.ID)] = true
+	}
+	if !usersResponse.HasMore {
+		o.publicIDsComplete = o.publicIDsFromStart
+	}
+	o.mu.Unlock()
+
 	var rv []*v2.Resource
 	for _, user := range usersResponse.Results {
-		ur, err := userResource(ctx, user)
+		userCopy := user
+		if o.scimClient == nil {
+			if !o.filter.allowsUser(publicUserEmail(user), user.Type == notion.UserTypeBot) {
+				continue
+			}
+
+			ur, err := userResource(ctx, &userCopy, nil, o.internalEmailDomains)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			rv = append(rv, ur)
+			continue
+		}
+
+		var scimUser *notionScim.User
+		var opts []rs.ResourceOption
+		su, inSCIM := scimUsers[normalizeID(user.ID)]
+		email := publicUserEmail(user)
+		if email == "" && inSCIM {
+			email = su.PrimaryEmail()
+		}
+		if !o.filter.allowsUser(email, user.Type == notion.UserTypeBot) {
+			continue
+		}
+
+		if inSCIM {
+			scimUser = &su
+		} else if user.Type == notion.UserTypePerson {
+			// Bots are never provisioned through SCIM, so only people are
+			// flagged as missing from it.
+			source, err := userSourceAnnotation(true, false)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			opts = append(opts, rs.WithAnnotation(source))
+		}
+
+		ur, err := userResource(ctx, &userCopy, scimUser, o.internalEmailDomains, opts...)
 		if err != nil {
 			return nil, "", nil, err
 		}
@@ -95,6 +397,81 @@ func (o *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, to
 	return rv, pageToken, nil, nil
 }
 
+// nextUsersPage returns the page state that follows the given one: public API
+// users are followed by the SCIM only users and then by guests, each when
+// enabled. It returns an empty string after the last page state.
+func (o *userResourceType) nextUsersPage(current string) string {
+	switch current {
+	case resourceTypeUser.Id:
+		if o.scimClient != nil {
+			return scimOnlyUsersPage
+		}
+		fallthrough
+	case scimOnlyUsersPage:
+		if o.discoverGuests {
+			return guestUsersPage
+		}
+	}
+	return ""
+}
+
+// nextUsersPageToken replaces the current page state with the next one.
+func nextUsersPageToken(bag *pagination.Bag, next string) (string, error) {
+	if next == "" {
+		return "", nil
+	}
+
+	bag.Pop()
+	bag.Push(pagination.PageState{ResourceTypeID: next})
+	return bag.Marshal()
+}
+
+// listSCIMOnlyUsers returns the SCIM users that weren't listed through the
+// public API. When the sync was resumed, users that weren't seen in this
+// process are looked up before being treated as SCIM only.
+func (o *userResourceType) listSCIMOnlyUsers(ctx context.Context, scimUsers map[string]notionScim.User) ([]*v2.Resource, error) {
+	ids := make([]string, 0, len(scimUsers))
+	for id := range scimUsers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var rv []*v2.Resource
+	for _, id := range ids {
+		o.mu.Lock()
+		seen := o.publicIDs[id]
+		o.mu.Unlock()
+		if seen {
+			continue
+		}
+
+		scimUser := scimUsers[id]
+		if !o.filter.allowsUser(scimUser.PrimaryEmail(), false) {
+			continue
+		}
+
+		_, err := o.client.FindUserByID(ctx, scimUser.ID)
+		switch {
+		case err == nil:
+			continue
+		case !isNotFound(err):
+			return nil, wrapError(err, "notion-connector: failed to get user %s", scimUser.ID)
+		}
+
+		source, err := userSourceAnnotation(false, true)
+		if err != nil {
+			return nil, err
+		}
+		ur, err := userResource(ctx, nil, &scimUser, o.internalEmailDomains, rs.WithAnnotation(source))
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, ur)
+	}
+
+	return rv, nil
+}
+
 func (o *userResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	return nil, "", nil, nil
 }
@@ -103,9 +480,15 @@ func (o *userResourceType) Grants(_ context.Context, _ *v2.Resource, _ *paginati
 	return nil, "", nil, nil
 }
 
-func userBuilder(client *notion.Client) *userResourceType {
+func userBuilder(client *notion.Client, scimClient *notionScim.ScimClient, scimStore *scimStore, discoverGuests bool, internalEmailDomains []string, filter *filter, pageSize int) *userResourceType {
 	return &userResourceType{
-		resourceType: resourceTypeUser,
-		client:       client,
+		resourceType:         resourceTypeUser,
+		client:               client,
+		scimClient:           scimClient,
+		scimStore:            scimStore,
+		discoverGuests:       discoverGuests,
+		internalEmailDomains: internalEmailDomains,
+		filter:               filter,
+		pageSize:             pageSize,
 	}
 }
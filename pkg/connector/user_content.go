@@ -0,0 +1,165 @@
+package connector
+
+import (
+	"context"
+
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/dstotijn/go-notion"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var listUserContentActionSchema = &v2.BatonActionSchema{
+	Name:        "list_user_content",
+	DisplayName: "List User Content",
+	Description: "Lists the pages and databases shared with the integration that a user created or last edited.",
+	Arguments: []*config.Field{
+		stringArgumentField("user_id", "User ID", "The resource ID of the Notion user.", true),
+	},
+	ReturnTypes: []*config.Field{
+		stringListReturnField("content", "Content", "The pages and databases, each as a JSON object with its id, object, title, url, created_by and last_edited_by."),
+	},
+}
+
+// contentItem is a page or database found by a search.
+type contentItem struct {
+	id           string
+	object       string
+	title        string
+	url          string
+	createdBy    string
+	lastEditedBy string
+}
+
+func pageTitle(page notion.Page) string {
+	switch props := page.Properties.(type) {
+	case notion.PageProperties:
+		return plainText(props.Title.Title)
+	case notion.DatabasePageProperties:
+		return databaseRowTitle(props)
+	default:
+		return ""
+	}
+}
+
+func contentItemFromSearchResult(result interface{}) (contentItem, bool) {
+	switch r := result.(type) {
+	case notion.Page:
+		item := contentItem{
+			id:     r.ID,
+			object: "page",
+			title:  pageTitle(r),
+			url:    r.URL,
+		}
+		if r.CreatedBy != nil {
+			item.createdBy = r.CreatedBy.ID
+		}
+		if r.LastEditedBy != nil {
+			item.lastEditedBy = r.LastEditedBy.ID
+		}
+		return item, true
+	case notion.Database:
+		return contentItem{
+			id:           r.ID,
+			object:       "database",
+			title:        plainText(r.Title),
+			url:          r.URL,
+			createdBy:    r.CreatedBy.ID,
+			lastEditedBy: r.LastEditedBy.ID,
+		}, true
+	default:
+		return contentItem{}, false
+	}
+}
+
+// searchContent pages through everything shared with the integration and
+// calls fn for every search result, which is a notion.Page or notion.Database.
+func searchContent(ctx context.Context, client *notion.Client, pageSize int, fn func(result interface{}) error) error {
+	var cursor string
+
+	for {
+		next, err := searchContentPage(ctx, client, cursor, pageSize, fn)
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// searchContentPage calls fn for every result of the search page at cursor,
+// and returns the cursor of the next page, or an empty string after the last.
+func searchContentPage(ctx context.Context, client *notion.Client, cursor string, pageSize int, fn func(result interface{}) error) (string, error) {
+	resp, err := client.Search(ctx, &notion.SearchOpts{
+		StartCursor: cursor,
+		PageSize:    pageSize,
+	})
+	if err != nil {
+		return "", wrapError(err, "notion-connector: failed to search content")
+	}
+
+	for _, result := range resp.Results {
+		if err := fn(result); err != nil {
+			return "", err
+		}
+	}
+
+	if !resp.HasMore || resp.NextCursor == nil {
+		return "", nil
+	}
+	return *resp.NextCursor, nil
+}
+
+// listUserContent returns the pages and databases a user created or last
+// edited. Notion search can't filter by author, so every page and database
+// visible to the integration is checked.
+func (nt *Notion) listUserContent(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	userID, err := stringArgument(args, "user_id")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var items []map[string]interface{}
+	err = searchContent(ctx, nt.client, nt.pageSize, func(result interface{}) error {
+		item, ok := contentItemFromSearchResult(result)
+		if !ok {
+			return nil
+		}
+
+		createdBy := sameID(item.createdBy, userID)
+		lastEditedBy := sameID(item.lastEditedBy, userID)
+		if !createdBy && !lastEditedBy {
+			return nil
+		}
+
+		items = append(items, map[string]interface{}{
+			"id":             item.id,
+			"object":         item.object,
+			"title":          item.title,
+			"url":            item.url,
+			"created_by":     createdBy,
+			"last_edited_by": lastEditedBy,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := jsonStrings(items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rv, err := structpb.NewStruct(map[string]interface{}{
+		"user_id": userID,
+		"content": content,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rv, nil, nil
+}
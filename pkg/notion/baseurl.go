@@ -0,0 +1,61 @@
+package notion
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BaseURLTransport returns a transport that sends requests for APIBaseURL to
+// apiBaseURL and requests for SCIMBaseURL to scimBaseURL through base. Empty
+// base URLs are left alone.
+func BaseURLTransport(base http.RoundTripper, apiBaseURL string, scimBaseURL string) (http.RoundTripper, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	t := &baseURLTransport{base: base}
+	for from, to := range map[string]string{APIBaseURL: apiBaseURL, SCIMBaseURL: scimBaseURL} {
+		if to == "" {
+			continue
+		}
+		if _, err := url.Parse(to); err != nil {
+			return nil, fmt.Errorf("notion-connector: invalid base URL %q: %w", to, err)
+		}
+		t.rewrites = append(t.rewrites, baseURLRewrite{from: from, to: strings.TrimSuffix(to, "/")})
+	}
+
+	return t, nil
+}
+
+type baseURLRewrite struct {
+	from string
+	to   string
+}
+
+type baseURLTransport struct {
+	base     http.RoundTripper
+	rewrites []baseURLRewrite
+}
+
+func (t *baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := req.URL.String()
+	for _, r := range t.rewrites {
+		if !strings.HasPrefix(u, r.from) {
+			continue
+		}
+
+		target, err := url.Parse(r.to + strings.TrimPrefix(u, r.from))
+		if err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		req.URL = target
+		req.Host = target.Host
+		break
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,255 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// The schemas of SCIM bulk requests and responses, as defined by RFC 7644,
+// section 3.7.
+const (
+	BulkRequestSchema  = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	BulkResponseSchema = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+)
+
+// bulkRequestOverhead is a generous estimate of the size of a bulk request
+// besides its operations, to keep requests under the maximum payload size.
+const bulkRequestOverhead = 256
+
+// BulkRequest is the body of a SCIM bulk request.
+type BulkRequest struct {
+	Schemas []string `json:"schemas"`
+	// FailOnErrors is the number of failed operations after which the server
+	// stops processing the request. Zero processes all of them.
+	FailOnErrors int             `json:"failOnErrors,omitempty"`
+	Operations   []BulkOperation `json:"Operations"`
+}
+
+// BulkOperation is an operation of a bulk request: a request to the resource
+// at Path, relative to the base URL, such as /Groups/{id}.
+type BulkOperation struct {
+	Method string      `json:"method"`
+	BulkID string      `json:"bulkId,omitempty"`
+	Path   string      `json:"path"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// BulkResponse is the body of the response to a bulk request.
+type BulkResponse struct {
+	Schemas    []string              `json:"schemas"`
+	Operations []BulkOperationResult `json:"Operations"`
+}
+
+// BulkOperationResult is the result of an operation of a bulk request.
+// Response holds the SCIM error of a failed operation.
+type BulkOperationResult struct {
+	Method   string          `json:"method"`
+	BulkID   string          `json:"bulkId,omitempty"`
+	Location string          `json:"location,omitempty"`
+	Status   BulkStatus      `json:"status"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// BulkStatus is the HTTP status of a bulk operation. RFC 7644 encodes it as a
+// string, but servers also send it as a number.
+type BulkStatus int
+
+func (s BulkStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.Itoa(int(s)))
+}
+
+func (s *BulkStatus) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case float64:
+		*s = BulkStatus(v)
+	case string:
+		status, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("notion-connector: invalid bulk operation status %q", v)
+		}
+		*s = BulkStatus(status)
+	default:
+		return fmt.Errorf("notion-connector: invalid bulk operation status %s", b)
+	}
+	return nil
+}
+
+// Bulk sends operations in one bulk request, and returns their results. The
+// server processes all of them, whether some fail or not. Callers must keep
+// within the limits of the BulkConfig of the server.
+func (c *ScimClient) Bulk(ctx context.Context, operations ...BulkOperation) ([]BulkOperationResult, error) {
+	req := BulkRequest{
+		Schemas:    []string{BulkRequestSchema},
+		Operations: operations,
+	}
+
+	var res BulkResponse
+	if err := c.do(ctx, http.MethodPost, "/Bulk", req, &res); err != nil {
+		return nil, err
+	}
+	return res.Operations, nil
+}
+
+// MembershipChange adds a user to a group, or removes them from it.
+type MembershipChange struct {
+	GroupID string
+	UserID  string
+	Remove  bool
+}
+
+func (m MembershipChange) patch() (PatchOp, error) {
+	if m.Remove {
+		return NewPatch().Remove(ValuePath("members", "value", m.UserID)).Build()
+	}
+	return NewPatch().Add(AttrPath("members"), []Member{{Value: m.UserID}}).Build()
+}
+
+// ChangeGroupMembers applies changes and returns the error of each, in order,
+// or nil for those that succeeded. When the ServiceProviderConfig of the
+// server says bulk requests are supported, the changes are sent in as few of
+// them as its limits allow. Otherwise, each change is a PATCH request.
+func (c *ScimClient) ChangeGroupMembers(ctx context.Context, changes []MembershipChange) []error {
+	errs := make([]error, len(changes))
+
+	operations := make([]BulkOperation, len(changes))
+	for i, change := range changes {
+		patch, err := change.patch()
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		operations[i] = BulkOperation{
+			Method: http.MethodPatch,
+			BulkID: strconv.Itoa(i),
+			Path:   "/Groups/" + change.GroupID,
+			Data:   patch,
+		}
+	}
+
+	bulk := c.bulkConfig(ctx)
+	if !bulk.Supported {
+		for i, op := range operations {
+			if errs[i] == nil {
+				errs[i] = c.PatchGroup(ctx, changes[i].GroupID, op.Data.(PatchOp))
+			}
+		}
+		return errs
+	}
+
+	for _, batch := range bulkBatches(operations, errs, bulk) {
+		c.bulkBatch(ctx, operations, batch, errs)
+	}
+	return errs
+}
+
+// bulkBatch sends the operations at the indexes of batch in one bulk request,
+// and records their errors in errs.
+func (c *ScimClient) bulkBatch(ctx context.Context, operations []BulkOperation, batch []int, errs []error) {
+	batchOps := make([]BulkOperation, 0, len(batch))
+	for _, i := range batch {
+		batchOps = append(batchOps, operations[i])
+	}
+
+	results, err := c.Bulk(ctx, batchOps...)
+	if err != nil {
+		for _, i := range batch {
+			errs[i] = err
+		}
+		return
+	}
+
+	byID := make(map[string]BulkOperationResult, len(results))
+	for _, result := range results {
+		byID[result.BulkID] = result
+	}
+
+	for _, i := range batch {
+		op := operations[i]
+		result, ok := byID[op.BulkID]
+		switch {
+		case !ok:
+			errs[i] = fmt.Errorf("notion-connector: bulk response has no result for %s %s", op.Method, op.Path)
+		case result.Status < http.StatusOK || result.Status >= http.StatusMultipleChoices:
+			scimErr := &SCIMError{
+				Method:     op.Method,
+				Path:       op.Path,
+				StatusCode: int(result.Status),
+			}
+			_ = json.Unmarshal(result.Response, scimErr)
+			errs[i] = scimErr
+		}
+	}
+}
+
+// bulkBatches splits the operations that have no error yet into batches of
+// indexes, each within the maximum number of operations and payload size of
+// a bulk request. An operation that exceeds the payload size on its own gets
+// an error instead.
+func bulkBatches(operations []BulkOperation, errs []error, config BulkConfig) [][]int {
+	var (
+		batches [][]int
+		batch   []int
+		size    = bulkRequestOverhead
+	)
+	for i, op := range operations {
+		if errs[i] != nil {
+			continue
+		}
+
+		b, err := json.Marshal(op)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if config.MaxPayloadSize > 0 && bulkRequestOverhead+len(b) > config.MaxPayloadSize {
+			errs[i] = fmt.Errorf("notion-connector: %s %s exceeds the maximum bulk payload size", op.Method, op.Path)
+			continue
+		}
+
+		full := config.MaxOperations > 0 && len(batch) == config.MaxOperations
+		tooLarge := config.MaxPayloadSize > 0 && size+len(b)+1 > config.MaxPayloadSize
+		if len(batch) > 0 && (full || tooLarge) {
+			batches = append(batches, batch)
+			batch, size = nil, bulkRequestOverhead
+		}
+		batch = append(batch, i)
+		size += len(b) + 1
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// bulkConfig returns the bulk configuration of the server, which is requested
+// once. Servers without a ServiceProviderConfig endpoint don't support bulk
+// requests; the configuration is requested again after other errors.
+func (c *ScimClient) bulkConfig(ctx context.Context) BulkConfig {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	if c.config != nil {
+		return c.config.Bulk
+	}
+
+	config, err := c.GetServiceProviderConfig(ctx)
+	if err != nil {
+		var scimErr *SCIMError
+		if errors.As(err, &scimErr) && (scimErr.StatusCode == http.StatusNotFound || scimErr.StatusCode == http.StatusNotImplemented) {
+			c.config = &ServiceProviderConfig{}
+		}
+		return BulkConfig{}
+	}
+
+	c.config = &config
+	return config.Bulk
+}
@@ -0,0 +1,218 @@
+package notion_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+)
+
+// addChanges returns changes adding user-0 to user-n-1 to group-0.
+func addChanges(n int) []notion.MembershipChange {
+	changes := make([]notion.MembershipChange, 0, n)
+	for i := 0; i < n; i++ {
+		changes = append(changes, notion.MembershipChange{GroupID: "group-0", UserID: fmt.Sprintf("user-%d", i)})
+	}
+	return changes
+}
+
+func checkNoErrors(t *testing.T, errs []error) {
+	t.Helper()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("change %d: %v", i, err)
+		}
+	}
+}
+
+func checkMembers(t *testing.T, s *notiontest.Server, n int) {
+	t.Helper()
+	group, _ := s.Group("group-0")
+	if len(group.Members) != n {
+		t.Errorf("got %d members, want %d", len(group.Members), n)
+	}
+}
+
+func TestChangeGroupMembersMaxOperations(t *testing.T) {
+	s := notiontest.NewServer()
+	defer s.Close()
+	addTestGroups(s, 1)
+	s.EnableBulk(3, 0)
+	client := newTestClient(s, 100)
+
+	errs := client.ChangeGroupMembers(context.Background(), addChanges(7))
+	checkNoErrors(t, errs)
+	checkMembers(t, s, 7)
+
+	// The server rejects bulk requests over its limits, so every request
+	// was within them.
+	if n := countRequests(s, 0, "POST /scim/v2/Bulk"); n != 3 {
+		t.Errorf("got %d bulk requests, want 3", n)
+	}
+	if n := countRequests(s, 0, "PATCH /scim/v2/Groups/group-0"); n != 0 {
+		t.Errorf("got %d PATCH requests, want 0", n)
+	}
+}
+
+func TestChangeGroupMembersMaxPayloadSize(t *testing.T) {
+	s := notiontest.NewServer()
+	defer s.Close()
+	addTestGroups(s, 1)
+
+	// Room for the envelope of the request and two operations, but not for
+	// a third.
+	patch, err := notion.NewPatch().Add(notion.AttrPath("members"), []notion.Member{{Value: "user-0"}}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, err := json.Marshal(notion.BulkOperation{Method: http.MethodPatch, BulkID: "0", Path: "/Groups/group-0", Data: patch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.EnableBulk(0, 256+3*(len(op)+1)-1)
+	client := newTestClient(s, 100)
+
+	errs := client.ChangeGroupMembers(context.Background(), addChanges(5))
+	checkNoErrors(t, errs)
+	checkMembers(t, s, 5)
+	if n := countRequests(s, 0, "POST /scim/v2/Bulk"); n != 3 {
+		t.Errorf("got %d bulk requests, want 3", n)
+	}
+
+	// A change too large for any bulk request fails alone.
+	large := notion.MembershipChange{GroupID: "group-0", UserID: strings.Repeat("x", 3*len(op))}
+	errs = client.ChangeGroupMembers(context.Background(), []notion.MembershipChange{large, addChanges(6)[5]})
+	if errs[0] == nil || errs[1] != nil {
+		t.Errorf("got errors %v, want one for the large change only", errs)
+	}
+	checkMembers(t, s, 6)
+}
+
+// bulkTransport answers the requests of a client for a server that supports
+// bulk requests, with the results of each in reverse order. Operations whose
+// bulkId is in failures fail with a conflict, and those in missing have no
+// result.
+func bulkTransport(t *testing.T, failures, missing []string) roundTripperFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		var body interface{}
+		switch req.URL.Path {
+		case "/scim/v2/ServiceProviderConfig":
+			body = notion.ServiceProviderConfig{Bulk: notion.BulkConfig{Supported: true, MaxOperations: 100}}
+		case "/scim/v2/Bulk":
+			var bulk notion.BulkRequest
+			if err := json.NewDecoder(req.Body).Decode(&bulk); err != nil {
+				t.Error(err)
+			}
+
+			var res notion.BulkResponse
+			for _, op := range slices.Backward(bulk.Operations) {
+				result := notion.BulkOperationResult{Method: op.Method, BulkID: op.BulkID, Status: http.StatusNoContent}
+				switch {
+				case slices.Contains(missing, op.BulkID):
+					continue
+				case slices.Contains(failures, op.BulkID):
+					result.Status = http.StatusConflict
+					result.Response = json.RawMessage(`{"scimType": "uniqueness", "detail": "conflict on ` + op.BulkID + `"}`)
+				}
+				res.Operations = append(res.Operations, result)
+			}
+			body = res
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/scim+json"}},
+			Body:       io.NopCloser(strings.NewReader(string(b))),
+			Request:    req,
+		}, nil
+	}
+}
+
+func TestChangeGroupMembersMapsResultsByBulkID(t *testing.T) {
+	client := notion.NewScimClient("scim-token", &http.Client{Transport: bulkTransport(t, []string{"1"}, []string{"3"})})
+
+	errs := client.ChangeGroupMembers(context.Background(), addChanges(5))
+
+	for _, i := range []int{0, 2, 4} {
+		if errs[i] != nil {
+			t.Errorf("change %d: %v", i, errs[i])
+		}
+	}
+
+	var scimErr *notion.SCIMError
+	if !errors.As(errs[1], &scimErr) {
+		t.Fatalf("got error %v for change 1, want a SCIM error", errs[1])
+	}
+	want := notion.SCIMError{
+		Method:     http.MethodPatch,
+		Path:       "/Groups/group-0",
+		StatusCode: http.StatusConflict,
+		ScimType:   "uniqueness",
+		Detail:     "conflict on 1",
+	}
+	if *scimErr != want {
+		t.Errorf("got %+v for change 1, want %+v", *scimErr, want)
+	}
+
+	if errs[3] == nil || !strings.Contains(errs[3].Error(), "no result") {
+		t.Errorf("got error %v for change 3, want a missing result", errs[3])
+	}
+}
+
+func TestChangeGroupMembersWithoutBulk(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(s *notiontest.Server)
+	}{
+		{
+			name:  "bulk unsupported",
+			setup: func(s *notiontest.Server) {},
+		},
+		{
+			name: "no service provider config",
+			setup: func(s *notiontest.Server) {
+				s.Fail(http.MethodGet, "/scim/v2/ServiceProviderConfig", http.StatusNotFound, "", 0)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := notiontest.NewServer()
+			defer s.Close()
+			addTestGroups(s, 1)
+			tt.setup(s)
+			client := newTestClient(s, 100)
+
+			checkNoErrors(t, client.ChangeGroupMembers(context.Background(), addChanges(3)))
+			remove := notion.MembershipChange{GroupID: "group-0", UserID: "user-0", Remove: true}
+			checkNoErrors(t, client.ChangeGroupMembers(context.Background(), []notion.MembershipChange{remove}))
+			checkMembers(t, s, 2)
+
+			if n := countRequests(s, 0, "PATCH /scim/v2/Groups/group-0"); n != 4 {
+				t.Errorf("got %d PATCH requests, want 4", n)
+			}
+			if n := countRequests(s, 0, "POST /scim/v2/Bulk"); n != 0 {
+				t.Errorf("got %d bulk requests, want 0", n)
+			}
+			// The configuration is requested once.
+			if n := countRequests(s, 0, "GET /scim/v2/ServiceProviderConfig"); n != 1 {
+				t.Errorf("got %d configuration requests, want 1", n)
+			}
+		})
+	}
+}
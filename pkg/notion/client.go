@@ -1,16 +1,24 @@
 package notion
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 )
 
 const baseUrl = "https://www.notion.so/scim/v2"
 
+// SCIMBaseURL is the base URL of the Notion SCIM API.
+const SCIMBaseURL = baseUrl
+
 const (
 	// 1-based not zero based.
 	defaultStartIndex = 1
@@ -20,13 +28,56 @@ const (
 type ScimClient struct {
 	httpClient *http.Client
 	scimToken  string
+	pageSize   int
+
+	// config is the ServiceProviderConfig of the server, once requested.
+	configMu sync.Mutex
+	config   *ServiceProviderConfig
 }
 
 func NewScimClient(scimToken string, httpClient *http.Client) *ScimClient {
 	return &ScimClient{
 		httpClient: httpClient,
 		scimToken:  scimToken,
+		pageSize:   defaultCount,
+	}
+}
+
+// SetPageSize sets the number of users or groups requested per page when
+// listing all of them.
+func (c *ScimClient) SetPageSize(pageSize int) {
+	c.pageSize = pageSize
+}
+
+// SCIMError is an error response of the SCIM API.
+type SCIMError struct {
+	Method     string `json:"-"`
+	Path       string `json:"-"`
+	StatusCode int    `json:"-"`
+	ScimType   string `json:"scimType"`
+	Detail     string `json:"detail"`
+}
+
+func (e *SCIMError) Error() string {
+	msg := fmt.Sprintf("notion-connector: %s %s returned status %d", e.Method, e.Path, e.StatusCode)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// ErrNotFound is returned by lookups that match no resource.
+var ErrNotFound = errors.New("notion-connector: not found")
+
+// IsNotFound reports whether err is a SCIM error for a missing resource, or
+// ErrNotFound.
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
 	}
+
+	var scimErr *SCIMError
+	return errors.As(err, &scimErr) && scimErr.StatusCode == http.StatusNotFound
 }
 
 type GroupsResponse struct {
@@ -36,69 +87,375 @@ type GroupsResponse struct {
 	ItemsPerPage int64   `json:"itemsPerPage"`
 }
 
-// GetGroups returns all Notion groups.
-func (c *ScimClient) GetGroups(ctx context.Context, count int, startIndex int) (GroupsResponse, error) {
-	groupsUrl := fmt.Sprint(baseUrl, "/Groups")
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, groupsUrl, nil)
-	if err != nil {
+// GetGroups returns one page of the Notion groups that match filter.
+func (c *ScimClient) GetGroups(ctx context.Context, count int, startIndex int, filter Filter) (GroupsResponse, error) {
+	var res GroupsResponse
+	if err := c.list(ctx, "/Groups", count, startIndex, filter, &res); err != nil {
 		return GroupsResponse{}, err
 	}
+	return res, nil
+}
 
-	q := url.Values{}
-	q.Add("count", strconv.Itoa(count))
-	q.Add("startIndex", strconv.Itoa(startIndex))
-	req.URL.RawQuery = q.Encode()
+// Groups returns all groups, requesting them a page at a time as the
+// iteration goes. It stops after the first error.
+func (c *ScimClient) Groups(ctx context.Context) iter.Seq2[Group, error] {
+	return c.FilterGroups(ctx, Filter{})
+}
 
-	var res GroupsResponse
-	groupsErr := c.doRequest(req, &res)
-	if groupsErr != nil {
-		return GroupsResponse{}, groupsErr
-	}
-	return res, nil
+// FilterGroups returns the groups that match filter, like Groups.
+func (c *ScimClient) FilterGroups(ctx context.Context, filter Filter) iter.Seq2[Group, error] {
+	return paginate(c.pageSize, func(count, startIndex int) ([]Group, int64, error) {
+		resp, err := c.GetGroups(ctx, count, startIndex, filter)
+		if err != nil {
+			return nil, 0, fmt.Errorf("notion-connector: failed to list groups: %w", err)
+		}
+		return resp.Resources, resp.TotalResults, nil
+	})
 }
 
 // GetPaginatedGroups returns all groups - paginated.
 func (c *ScimClient) GetPaginatedGroups(ctx context.Context) ([]Group, error) {
-	var allGroups []Group
-	count := defaultCount
-	totalReturned := 0
-	pageIndex := defaultStartIndex
+	return collect(c.Groups(ctx))
+}
 
-	for {
-		resp, err := c.GetGroups(ctx, count, pageIndex)
-		if err != nil {
-			return nil, fmt.Errorf("notion-connector: failed to list groups: %w", err)
-		}
+// GetGroup returns group details by group ID.
+func (c *ScimClient) GetGroup(ctx context.Context, groupId string) (Group, error) {
+	var res Group
+	if err := c.do(ctx, http.MethodGet, "/Groups/"+groupId, nil, &res); err != nil {
+		return Group{}, err
+	}
+	return res, nil
+}
+
+// FindGroupByDisplayName returns the group with the given display name. It
+// returns ErrNotFound if there is none, and an error if several groups have
+// the name.
+func (c *ScimClient) FindGroupByDisplayName(ctx context.Context, displayName string) (Group, error) {
+	filter := Eq("displayName", displayName)
+	resp, err := c.GetGroups(ctx, 2, defaultStartIndex, filter)
+	if err != nil {
+		return Group{}, err
+	}
+
+	switch {
+	case len(resp.Resources) == 0:
+		return Group{}, fmt.Errorf("%w: no group matches %s", ErrNotFound, filter)
+	case len(resp.Resources) > 1:
+		return Group{}, fmt.Errorf("notion-connector: several groups match %s", filter)
+	}
+	return resp.Resources[0], nil
+}
+
+// CreateGroup creates a group and returns it as created.
+func (c *ScimClient) CreateGroup(ctx context.Context, group Group) (Group, error) {
+	if len(group.Schemas) == 0 {
+		group.Schemas = []string{GroupSchema}
+	}
+
+	var res Group
+	if err := c.do(ctx, http.MethodPost, "/Groups", group, &res); err != nil {
+		return Group{}, err
+	}
+	return res, nil
+}
+
+// ReplaceGroup replaces the group with the ID of group and returns it as
+// replaced.
+func (c *ScimClient) ReplaceGroup(ctx context.Context, group Group) (Group, error) {
+	if len(group.Schemas) == 0 {
+		group.Schemas = []string{GroupSchema}
+	}
 
-		totalReturned += int(resp.ItemsPerPage)
+	var res Group
+	if err := c.do(ctx, http.MethodPut, "/Groups/"+group.ID, group, &res); err != nil {
+		return Group{}, err
+	}
+	return res, nil
+}
 
-		allGroups = append(resp.Resources, allGroups...)
+// PatchGroup applies the operations of patch to a group.
+func (c *ScimClient) PatchGroup(ctx context.Context, groupId string, patch PatchOp) error {
+	return c.do(ctx, http.MethodPatch, "/Groups/"+groupId, patch, nil)
+}
 
-		if totalReturned >= int(resp.TotalResults) {
-			break
+// DeleteGroup deletes a group.
+func (c *ScimClient) DeleteGroup(ctx context.Context, groupId string) error {
+	return c.do(ctx, http.MethodDelete, "/Groups/"+groupId, nil, nil)
+}
+
+type UsersResponse struct {
+	TotalResults int64  `json:"totalResults"`
+	Resources    []User `json:"Resources"`
+	StartIndex   int64  `json:"startIndex"`
+	ItemsPerPage int64  `json:"itemsPerPage"`
+}
+
+// GetUsers returns one page of the Notion users that match filter.
+func (c *ScimClient) GetUsers(ctx context.Context, count int, startIndex int, filter Filter) (UsersResponse, error) {
+	var res UsersResponse
+	if err := c.list(ctx, "/Users", count, startIndex, filter, &res); err != nil {
+		return UsersResponse{}, err
+	}
+	return res, nil
+}
+
+// Users returns all users, requesting them a page at a time as the iteration
+// goes. It stops after the first error.
+func (c *ScimClient) Users(ctx context.Context) iter.Seq2[User, error] {
+	return c.FilterUsers(ctx, Filter{})
+}
+
+// FilterUsers returns the users that match filter, like Users.
+func (c *ScimClient) FilterUsers(ctx context.Context, filter Filter) iter.Seq2[User, error] {
+	return paginate(c.pageSize, func(count, startIndex int) ([]User, int64, error) {
+		resp, err := c.GetUsers(ctx, count, startIndex, filter)
+		if err != nil {
+			return nil, 0, fmt.Errorf("notion-connector: failed to list users: %w", err)
 		}
-		pageIndex += 1
+		return resp.Resources, resp.TotalResults, nil
+	})
+}
+
+// GetPaginatedUsers returns all users - paginated.
+func (c *ScimClient) GetPaginatedUsers(ctx context.Context) ([]User, error) {
+	return collect(c.Users(ctx))
+}
+
+// GetUser returns a user by ID.
+func (c *ScimClient) GetUser(ctx context.Context, userId string) (User, error) {
+	var res User
+	if err := c.do(ctx, http.MethodGet, "/Users/"+userId, nil, &res); err != nil {
+		return User{}, err
 	}
+	return res, nil
+}
 
-	return allGroups, nil
+// FindUserByEmail returns the user with the given email address. It returns
+// ErrNotFound if there is none, and an error if several users have the
+// address. Notion filters users by the email attribute rather than by
+// emails.value.
+func (c *ScimClient) FindUserByEmail(ctx context.Context, email string) (User, error) {
+	return c.findUser(ctx, Eq("email", email))
 }
 
-// GetGroup returns group details by group ID.
-func (c *ScimClient) GetGroup(ctx context.Context, groupId string) (Group, error) {
-	url := fmt.Sprint(baseUrl, "/Groups/", groupId)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// FindUserByUserName returns the user with the given user name, like
+// FindUserByEmail.
+func (c *ScimClient) FindUserByUserName(ctx context.Context, userName string) (User, error) {
+	return c.findUser(ctx, Eq("userName", userName))
+}
+
+func (c *ScimClient) findUser(ctx context.Context, filter Filter) (User, error) {
+	resp, err := c.GetUsers(ctx, 2, defaultStartIndex, filter)
 	if err != nil {
-		return Group{}, err
+		return User{}, err
 	}
 
-	var res Group
-	groupErr := c.doRequest(req, &res)
-	if groupErr != nil {
-		return Group{}, groupErr
+	switch {
+	case len(resp.Resources) == 0:
+		return User{}, fmt.Errorf("%w: no user matches %s", ErrNotFound, filter)
+	case len(resp.Resources) > 1:
+		return User{}, fmt.Errorf("notion-connector: several users match %s", filter)
+	}
+	return resp.Resources[0], nil
+}
+
+// CreateUser creates a user, which adds them to the workspace, and returns the
+// user as created. Active is sent as is, so it must be set for an active user.
+func (c *ScimClient) CreateUser(ctx context.Context, user User) (User, error) {
+	if len(user.Schemas) == 0 {
+		user.Schemas = []string{UserSchema}
+	}
+
+	var res User
+	if err := c.do(ctx, http.MethodPost, "/Users", user, &res); err != nil {
+		return User{}, err
+	}
+	return res, nil
+}
+
+// ReplaceUser replaces the user with the ID of user and returns it as
+// replaced.
+func (c *ScimClient) ReplaceUser(ctx context.Context, user User) (User, error) {
+	if len(user.Schemas) == 0 {
+		user.Schemas = []string{UserSchema}
+	}
+
+	var res User
+	if err := c.do(ctx, http.MethodPut, "/Users/"+user.ID, user, &res); err != nil {
+		return User{}, err
+	}
+	return res, nil
+}
+
+// PatchUser applies the operations of patch to a user.
+func (c *ScimClient) PatchUser(ctx context.Context, userId string, patch PatchOp) error {
+	return c.do(ctx, http.MethodPatch, "/Users/"+userId, patch, nil)
+}
+
+// DeleteUser removes a user from the workspace.
+func (c *ScimClient) DeleteUser(ctx context.Context, userId string) error {
+	return c.do(ctx, http.MethodDelete, "/Users/"+userId, nil, nil)
+}
+
+// GetServiceProviderConfig returns the features of the SCIM API the server
+// supports.
+func (c *ScimClient) GetServiceProviderConfig(ctx context.Context) (ServiceProviderConfig, error) {
+	var res ServiceProviderConfig
+	if err := c.do(ctx, http.MethodGet, "/ServiceProviderConfig", nil, &res); err != nil {
+		return ServiceProviderConfig{}, err
 	}
 	return res, nil
 }
 
+// AddGroupMember adds a user to a group.
+func (c *ScimClient) AddGroupMember(ctx context.Context, groupId string, userId string) error {
+	patch, err := NewPatch().Add(AttrPath("members"), []Member{{Value: userId}}).Build()
+	if err != nil {
+		return err
+	}
+
+	return c.PatchGroup(ctx, groupId, patch)
+}
+
+// RemoveGroupMember removes a user from a group.
+func (c *ScimClient) RemoveGroupMember(ctx context.Context, groupId string, userId string) error {
+	patch, err := NewPatch().Remove(ValuePath("members", "value", userId)).Build()
+	if err != nil {
+		return err
+	}
+
+	return c.PatchGroup(ctx, groupId, patch)
+}
+
+// RenameGroup changes the display name of a group.
+func (c *ScimClient) RenameGroup(ctx context.Context, groupId string, displayName string) error {
+	patch, err := NewPatch().Replace(AttrPath("displayName"), displayName).Build()
+	if err != nil {
+		return err
+	}
+
+	return c.PatchGroup(ctx, groupId, patch)
+}
+
+// DeactivateUser marks a user as inactive, which removes them from the workspace.
+func (c *ScimClient) DeactivateUser(ctx context.Context, userId string) error {
+	patch, err := NewPatch().Replace(AttrPath("active"), false).Build()
+	if err != nil {
+		return err
+	}
+
+	return c.PatchUser(ctx, userId, patch)
+}
+
+// UserIDs returns the IDs of all users, requesting only the id attribute of
+// each as the iteration goes. Servers that ignore the attributes parameter
+// send whole users, of which only the ID is kept.
+func (c *ScimClient) UserIDs(ctx context.Context) iter.Seq2[string, error] {
+	return c.ids(ctx, "/Users")
+}
+
+// GroupIDs returns the IDs of all groups, like UserIDs.
+func (c *ScimClient) GroupIDs(ctx context.Context) iter.Seq2[string, error] {
+	return c.ids(ctx, "/Groups")
+}
+
+func (c *ScimClient) ids(ctx context.Context, path string) iter.Seq2[string, error] {
+	return paginate(c.pageSize, func(count, startIndex int) ([]string, int64, error) {
+		q := url.Values{}
+		q.Add("attributes", "id")
+		q.Add("count", strconv.Itoa(count))
+		q.Add("startIndex", strconv.Itoa(startIndex))
+
+		var res struct {
+			TotalResults int64 `json:"totalResults"`
+			Resources    []struct {
+				ID string `json:"id"`
+			} `json:"Resources"`
+		}
+		if err := c.do(ctx, http.MethodGet, path+"?"+q.Encode(), nil, &res); err != nil {
+			return nil, 0, fmt.Errorf("notion-connector: failed to list IDs: %w", err)
+		}
+
+		ids := make([]string, 0, len(res.Resources))
+		for _, resource := range res.Resources {
+			ids = append(ids, resource.ID)
+		}
+		return ids, res.TotalResults, nil
+	})
+}
+
+// paginate returns the items of all pages, which fetch requests by 1-based
+// start index as the iteration goes.
+func paginate[T any](pageSize int, fetch func(count, startIndex int) ([]T, int64, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		startIndex := defaultStartIndex
+		for {
+			items, total, err := fetch(pageSize, startIndex)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			startIndex += len(items)
+			if len(items) == 0 || int64(startIndex-defaultStartIndex) >= total {
+				return
+			}
+		}
+	}
+}
+
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var rv []T
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, item)
+	}
+	return rv, nil
+}
+
+func (c *ScimClient) list(ctx context.Context, path string, count int, startIndex int, filter Filter, res interface{}) error {
+	q := url.Values{}
+	q.Add("count", strconv.Itoa(count))
+	q.Add("startIndex", strconv.Itoa(startIndex))
+	if !filter.IsZero() {
+		q.Add("filter", filter.String())
+	}
+
+	return c.do(ctx, http.MethodGet, path+"?"+q.Encode(), nil, res)
+}
+
+// do sends a request to the SCIM API, with body encoded as JSON unless it is
+// nil, and decodes the response into res unless it is nil.
+func (c *ScimClient) do(ctx context.Context, method string, path string, body interface{}, res interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, baseUrl+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Add("Content-Type", "application/scim+json")
+	}
+
+	return c.doRequest(req, res)
+}
+
 func (c *ScimClient) doRequest(req *http.Request, resType interface{}) error {
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.scimToken))
 	req.Header.Add("accept", "application/json")
@@ -109,6 +466,22 @@ func (c *ScimClient) doRequest(req *http.Request, resType interface{}) error {
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		scimErr := &SCIMError{
+			Method:     req.Method,
+			Path:       req.URL.Path,
+			StatusCode: resp.StatusCode,
+		}
+		// The body is a SCIM error message when the API sent it, but it
+		// doesn't have to be.
+		_ = json.NewDecoder(resp.Body).Decode(scimErr)
+		return scimErr
+	}
+
+	if resType == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
 	if err := json.NewDecoder(resp.Body).Decode(&resType); err != nil {
 		return err
 	}
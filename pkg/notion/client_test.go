@@ -0,0 +1,337 @@
+package notion_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+)
+
+// newTestClient returns a SCIM client for s that requests pageSize resources
+// per page.
+func newTestClient(s *notiontest.Server, pageSize int) *notion.ScimClient {
+	client := notion.NewScimClient("scim-token", s.Client())
+	client.SetPageSize(pageSize)
+	return client
+}
+
+// countRequests returns the number of requests s received with the given
+// method and path since it had received before requests.
+func countRequests(s *notiontest.Server, before int, request string) int {
+	var n int
+	for _, r := range s.Requests()[before:] {
+		if r == request {
+			n++
+		}
+	}
+	return n
+}
+
+func addTestUsers(s *notiontest.Server, n int) {
+	for i := 0; i < n; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		s.AddSCIMUsers(notion.User{
+			ID:       fmt.Sprintf("user-%d", i),
+			UserName: email,
+			Emails:   []notion.Email{{Value: email, Primary: true}},
+			Active:   true,
+		})
+	}
+}
+
+func addTestGroups(s *notiontest.Server, n int) {
+	for i := 0; i < n; i++ {
+		s.AddGroups(notion.Group{
+			ID:          fmt.Sprintf("group-%d", i),
+			DisplayName: fmt.Sprintf("Group %d", i),
+		})
+	}
+}
+
+func TestUsersIterator(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	addTestUsers(s, 7)
+	client := newTestClient(s, 3)
+
+	var ids []string
+	for user, err := range client.Users(ctx) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+
+	want := "user-0 user-1 user-2 user-3 user-4 user-5 user-6"
+	if got := strings.Join(ids, " "); got != want {
+		t.Errorf("got users %s, want %s", got, want)
+	}
+	if n := countRequests(s, 0, "GET /scim/v2/Users"); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+}
+
+func TestGroupsIterator(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	addTestGroups(s, 6)
+	client := newTestClient(s, 3)
+
+	groups, err := client.GetPaginatedGroups(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 6 || groups[0].ID != "group-0" || groups[5].ID != "group-5" {
+		t.Errorf("got %d groups, want group-0 to group-5", len(groups))
+	}
+	// The second page ends at the total, so no empty page is requested.
+	if n := countRequests(s, 0, "GET /scim/v2/Groups"); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
+
+func TestIteratorStopsEarly(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	addTestGroups(s, 10)
+	client := newTestClient(s, 3)
+
+	var n int
+	for _, err := range client.Groups(ctx) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if n == 4 {
+			break
+		}
+	}
+
+	if n := countRequests(s, 0, "GET /scim/v2/Groups"); n != 2 {
+		t.Errorf("got %d requests after stopping on the second page, want 2", n)
+	}
+}
+
+func TestIteratorStopsAfterError(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	addTestUsers(s, 7)
+	client := newTestClient(s, 3)
+
+	var n int
+	var errs []error
+	for _, err := range client.Users(ctx) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+		if n == 1 {
+			s.Fail(http.MethodGet, "/scim/v2/Users", http.StatusInternalServerError, "", 0)
+		}
+	}
+
+	if n != 3 || len(errs) != 1 {
+		t.Fatalf("got %d users and %d errors, want 3 users and 1 error", n, len(errs))
+	}
+	var scimErr *notion.SCIMError
+	if !errors.As(errs[0], &scimErr) || scimErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got error %v, want a SCIM error with status 500", errs[0])
+	}
+}
+
+func TestUserCRUD(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	client := newTestClient(s, 100)
+
+	created, err := client.CreateUser(ctx, notion.User{
+		UserName: "alice@example.com",
+		Emails:   []notion.Email{{Value: "alice@example.com", Primary: true}},
+		Active:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || len(created.Schemas) != 1 || created.Schemas[0] != notion.UserSchema {
+		t.Fatalf("got created user %+v, want an ID and the user schema", created)
+	}
+
+	created.Title = "Engineer"
+	replaced, err := client.ReplaceUser(ctx, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.ID != created.ID || replaced.Title != "Engineer" {
+		t.Errorf("got replaced user %+v, want title Engineer", replaced)
+	}
+
+	if err := client.DeactivateUser(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	user, err := client.GetUser(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Active || user.Title != "Engineer" {
+		t.Errorf("got user %+v, want an inactive engineer", user)
+	}
+
+	if err := client.DeleteUser(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetUser(ctx, created.ID); !notion.IsNotFound(err) {
+		t.Errorf("got error %v getting a deleted user, want not found", err)
+	}
+}
+
+func TestGroupCRUD(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	client := newTestClient(s, 100)
+
+	created, err := client.CreateGroup(ctx, notion.Group{DisplayName: "Engineering"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" {
+		t.Fatal("got a created group without an ID")
+	}
+
+	if err := client.AddGroupMember(ctx, created.ID, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddGroupMember(ctx, created.ID, "user-2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveGroupMember(ctx, created.ID, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RenameGroup(ctx, created.ID, "Platform"); err != nil {
+		t.Fatal(err)
+	}
+
+	group, err := client.FindGroupByDisplayName(ctx, "Platform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.ID != created.ID || len(group.Members) != 1 || group.Members[0].Value != "user-2" {
+		t.Errorf("got group %+v, want %s with member user-2", group, created.ID)
+	}
+
+	group.Members = nil
+	replaced, err := client.ReplaceGroup(ctx, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.ID != created.ID || len(replaced.Members) != 0 {
+		t.Errorf("got replaced group %+v, want no members", replaced)
+	}
+
+	if err := client.DeleteGroup(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetGroup(ctx, created.ID); !notion.IsNotFound(err) {
+		t.Errorf("got error %v getting a deleted group, want not found", err)
+	}
+	if _, err := client.FindGroupByDisplayName(ctx, "Platform"); !notion.IsNotFound(err) {
+		t.Errorf("got error %v finding a deleted group, want not found", err)
+	}
+}
+
+func TestSCIMError(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	addTestUsers(s, 1)
+	client := newTestClient(s, 100)
+
+	_, err := client.CreateUser(ctx, notion.User{UserName: "user0@example.com", Active: true})
+	var scimErr *notion.SCIMError
+	if !errors.As(err, &scimErr) {
+		t.Fatalf("got error %v, want a SCIM error", err)
+	}
+	want := notion.SCIMError{
+		Method:     http.MethodPost,
+		Path:       "/scim/v2/Users",
+		StatusCode: http.StatusConflict,
+		ScimType:   "uniqueness",
+		Detail:     "User user0@example.com already exists",
+	}
+	if *scimErr != want {
+		t.Errorf("got %+v, want %+v", *scimErr, want)
+	}
+	if notion.IsNotFound(err) {
+		t.Error("got a conflict reported as not found")
+	}
+	if got := err.Error(); got != "notion-connector: POST /scim/v2/Users returned status 409: User user0@example.com already exists" {
+		t.Errorf("got message %q", got)
+	}
+
+	_, err = client.GetUser(ctx, "user-missing")
+	if !errors.As(err, &scimErr) || scimErr.StatusCode != http.StatusNotFound || !notion.IsNotFound(err) {
+		t.Errorf("got error %v, want a SCIM error with status 404", err)
+	}
+}
+
+func TestFindUser(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	addTestUsers(s, 3)
+	s.AddSCIMUsers(
+		notion.User{ID: "user-shared-1", UserName: "a@example.com", Emails: []notion.Email{{Value: "shared@example.com"}}},
+		notion.User{ID: "user-shared-2", UserName: "b@example.com", Emails: []notion.Email{{Value: "shared@example.com"}}},
+	)
+	client := newTestClient(s, 100)
+
+	user, err := client.FindUserByEmail(ctx, "USER1@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != "user-1" {
+		t.Errorf("got user %s by email, want user-1", user.ID)
+	}
+
+	user, err = client.FindUserByUserName(ctx, "user2@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != "user-2" {
+		t.Errorf("got user %s by user name, want user-2", user.ID)
+	}
+
+	if _, err := client.FindUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, notion.ErrNotFound) {
+		t.Errorf("got error %v for an unknown address, want ErrNotFound", err)
+	}
+
+	_, err = client.FindUserByEmail(ctx, "shared@example.com")
+	if err == nil || notion.IsNotFound(err) {
+		t.Errorf("got error %v for a shared address, want an ambiguity error", err)
+	}
+}
+
+func TestUnsupportedFilter(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	client := newTestClient(s, 100)
+
+	filter := notion.Or(notion.Eq("userName", "a@example.com"), notion.Eq("userName", "b@example.com"))
+	_, err := client.GetUsers(ctx, 10, 1, filter)
+	var scimErr *notion.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("got error %v for a filter the fake doesn't support, want status 400", err)
+	}
+}
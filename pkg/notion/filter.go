@@ -0,0 +1,135 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Filter is a SCIM filter expression, as defined by RFC 7644, section
+// 3.4.2.2. The zero Filter matches everything.
+type Filter struct {
+	expr string
+	// op is the logical operator joining the operands of the expression, if
+	// any, to tell when it needs parentheses as an operand itself.
+	op string
+}
+
+func compare(attr string, op string, value interface{}) Filter {
+	return Filter{expr: attr + " " + op + " " + compareValue(value)}
+}
+
+// compareValue formats the value of a comparison, which is a JSON literal.
+func compareValue(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%q", fmt.Sprint(value))
+	}
+	return string(b)
+}
+
+// Eq matches resources whose attribute attr equals value.
+func Eq(attr string, value interface{}) Filter {
+	return compare(attr, "eq", value)
+}
+
+// Ne matches resources whose attribute attr doesn't equal value.
+func Ne(attr string, value interface{}) Filter {
+	return compare(attr, "ne", value)
+}
+
+// Co matches resources whose attribute attr contains value.
+func Co(attr string, value string) Filter {
+	return compare(attr, "co", value)
+}
+
+// Sw matches resources whose attribute attr starts with value.
+func Sw(attr string, value string) Filter {
+	return compare(attr, "sw", value)
+}
+
+// Ew matches resources whose attribute attr ends with value.
+func Ew(attr string, value string) Filter {
+	return compare(attr, "ew", value)
+}
+
+// Gt matches resources whose attribute attr is greater than value.
+func Gt(attr string, value interface{}) Filter {
+	return compare(attr, "gt", value)
+}
+
+// Ge matches resources whose attribute attr is greater than or equal to value.
+func Ge(attr string, value interface{}) Filter {
+	return compare(attr, "ge", value)
+}
+
+// Lt matches resources whose attribute attr is less than value.
+func Lt(attr string, value interface{}) Filter {
+	return compare(attr, "lt", value)
+}
+
+// Le matches resources whose attribute attr is less than or equal to value.
+func Le(attr string, value interface{}) Filter {
+	return compare(attr, "le", value)
+}
+
+// Pr matches resources that have a value for the attribute attr.
+func Pr(attr string) Filter {
+	return Filter{expr: attr + " pr"}
+}
+
+// And matches resources that all filters match. Zero filters are skipped.
+func And(filters ...Filter) Filter {
+	return join("and", filters)
+}
+
+// Or matches resources that any of filters matches. Zero filters are
+// skipped.
+func Or(filters ...Filter) Filter {
+	return join("or", filters)
+}
+
+func join(op string, filters []Filter) Filter {
+	var operands []string
+	for _, f := range filters {
+		if f.IsZero() {
+			continue
+		}
+		if f.op != "" && f.op != op {
+			operands = append(operands, "("+f.expr+")")
+			continue
+		}
+		operands = append(operands, f.expr)
+	}
+
+	switch len(operands) {
+	case 0:
+		return Filter{}
+	case 1:
+		// A single operand keeps its own operator.
+		for _, f := range filters {
+			if !f.IsZero() {
+				return f
+			}
+		}
+	}
+
+	return Filter{expr: strings.Join(operands, " "+op+" "), op: op}
+}
+
+// Not matches resources that f doesn't match.
+func Not(f Filter) Filter {
+	if f.IsZero() {
+		return f
+	}
+	return Filter{expr: "not (" + f.expr + ")"}
+}
+
+// IsZero reports whether f is the zero Filter.
+func (f Filter) IsZero() bool {
+	return f.expr == ""
+}
+
+func (f Filter) String() string {
+	return f.expr
+}
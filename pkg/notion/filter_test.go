@@ -0,0 +1,117 @@
+package notion
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	lastModified := time.Date(2024, 5, 13, 4, 42, 34, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{
+			name:   "zero",
+			filter: Filter{},
+			want:   "",
+		},
+		{
+			name:   "string value",
+			filter: Eq("userName", "bjensen@example.com"),
+			want:   `userName eq "bjensen@example.com"`,
+		},
+		{
+			name:   "quotes and backslashes in values",
+			filter: Eq("displayName", `The "A" Team \ Ops`),
+			want:   `displayName eq "The \"A\" Team \\ Ops"`,
+		},
+		{
+			name:   "boolean and number values",
+			filter: And(Eq("active", true), Ge("meta.version", 3)),
+			want:   `active eq true and meta.version ge 3`,
+		},
+		{
+			name:   "time value",
+			filter: Gt("meta.lastModified", lastModified),
+			want:   `meta.lastModified gt "2024-05-13T04:42:34Z"`,
+		},
+		{
+			name:   "null value",
+			filter: Ne("title", nil),
+			want:   `title ne null`,
+		},
+		{
+			name:   "string operators",
+			filter: Or(Co("name.familyName", "O'Malley"), Sw("userName", "J"), Ew("title", "er")),
+			want:   `name.familyName co "O'Malley" or userName sw "J" or title ew "er"`,
+		},
+		{
+			name:   "present",
+			filter: And(Pr("title"), Lt("meta.lastModified", "2011-05-13T04:42:34Z"), Le("meta.version", 2)),
+			want:   `title pr and meta.lastModified lt "2011-05-13T04:42:34Z" and meta.version le 2`,
+		},
+		{
+			name:   "or inside and",
+			filter: And(Eq("userType", "Employee"), Or(Co("emails", "example.com"), Co("emails", "example.org"))),
+			want:   `userType eq "Employee" and (emails co "example.com" or emails co "example.org")`,
+		},
+		{
+			name:   "and inside or",
+			filter: Or(And(Eq("a", 1), Eq("b", 2)), Eq("c", 3)),
+			want:   `(a eq 1 and b eq 2) or c eq 3`,
+		},
+		{
+			name:   "same operator isn't parenthesized",
+			filter: And(And(Eq("a", 1), Eq("b", 2)), Eq("c", 3)),
+			want:   `a eq 1 and b eq 2 and c eq 3`,
+		},
+		{
+			name:   "zero operands are skipped",
+			filter: And(Filter{}, Eq("a", 1), Filter{}),
+			want:   `a eq 1`,
+		},
+		{
+			name:   "single operand keeps its operator",
+			filter: And(Filter{}, Or(Eq("a", 1), Eq("b", 2)), Eq("c", 3)),
+			want:   `(a eq 1 or b eq 2) and c eq 3`,
+		},
+		{
+			name:   "not",
+			filter: Not(Or(Eq("a", 1), Eq("b", 2))),
+			want:   `not (a eq 1 or b eq 2)`,
+		},
+		{
+			name:   "not inside or",
+			filter: Or(Not(Eq("a", 1)), Eq("b", 2)),
+			want:   `not (a eq 1) or b eq 2`,
+		},
+		{
+			name:   "not of zero",
+			filter: Not(Filter{}),
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if got := tt.filter.IsZero(); got != (tt.want == "") {
+				t.Errorf("got IsZero %v", got)
+			}
+		})
+	}
+}
+
+func TestFilterOperandOfSingleJoin(t *testing.T) {
+	// A join of one operand is that operand, so it is parenthesized by
+	// its own operator when joined again.
+	f := And(Or(Eq("a", 1), Eq("b", 2)))
+	if got, want := Or(f, Eq("c", 3)).String(), `a eq 1 or b eq 2 or c eq 3`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -1,14 +1,162 @@
 package notion
 
+import "time"
+
+// The schemas of the SCIM core resources.
+const (
+	UserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+)
+
 type Group struct {
 	Schemas     []string `json:"schemas"`
-	ID          string   `json:"id"`
+	ID          string   `json:"id,omitempty"`
 	DisplayName string   `json:"displayName"`
 	Members     []Member `json:"members"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Meta holds the metadata of a SCIM resource. Notion sets it on the resources
+// it returns, and ignores it in requests.
+type Meta struct {
+	ResourceType string    `json:"resourceType,omitempty"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
 }
 
 type Member struct {
-	Value string `json:"value"`
-	Ref   string `json:"$ref"`
-	Type  string `json:"type"`
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+const PatchOpSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+
+// PatchOp is the body of a SCIM PATCH request.
+type PatchOp struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func NewPatchOp(operations ...PatchOperation) PatchOp {
+	return PatchOp{
+		Schemas:    []string{PatchOpSchema},
+		Operations: operations,
+	}
+}
+
+// ServiceProviderConfig describes the features of the SCIM API a server
+// supports, as defined by RFC 7643, section 5.
+type ServiceProviderConfig struct {
+	Schemas []string     `json:"schemas"`
+	Patch   Supported    `json:"patch"`
+	Bulk    BulkConfig   `json:"bulk"`
+	Filter  FilterConfig `json:"filter"`
+	Sort    Supported    `json:"sort"`
+	ETag    Supported    `json:"etag"`
+}
+
+// Supported tells whether a feature of the SCIM API is supported.
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+// BulkConfig tells whether bulk requests are supported, and their limits.
+type BulkConfig struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+// FilterConfig tells whether filters are supported, and the maximum number
+// of resources returned.
+type FilterConfig struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+const EnterpriseUserSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+type User struct {
+	Schemas    []string        `json:"schemas"`
+	ID         string          `json:"id,omitempty"`
+	UserName   string          `json:"userName"`
+	Name       Name            `json:"name"`
+	Title      string          `json:"title,omitempty"`
+	Emails     []Email         `json:"emails,omitempty"`
+	Photos     []Photo         `json:"photos,omitempty"`
+	Active     bool            `json:"active"`
+	Enterprise *EnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta       *Meta           `json:"meta,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted"`
+	GivenName  string `json:"givenName"`
+	FamilyName string `json:"familyName"`
+}
+
+// Email is an email address of a user. Type and Primary are only sent when
+// set: strict SCIM servers reject an empty type, and SCIM treats a missing
+// primary as false.
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type Photo struct {
+	Value   string `json:"value"`
+	Type    string `json:"type"`
+	Primary bool   `json:"primary"`
+}
+
+// EnterpriseUser holds the attributes of the SCIM enterprise user extension.
+type EnterpriseUser struct {
+	EmployeeNumber string   `json:"employeeNumber"`
+	CostCenter     string   `json:"costCenter"`
+	Organization   string   `json:"organization"`
+	Division       string   `json:"division"`
+	Department     string   `json:"department"`
+	Manager        *Manager `json:"manager,omitempty"`
+}
+
+type Manager struct {
+	Value       string `json:"value"`
+	DisplayName string `json:"displayName"`
+}
+
+// PrimaryEmail returns the primary email address of the user, falling back to
+// the user name, which Notion sets to the email address.
+func (u User) PrimaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return u.UserName
+}
+
+// Photo returns the URL of the primary photo of the user, if any.
+func (u User) Photo() string {
+	for _, photo := range u.Photos {
+		if photo.Primary {
+			return photo.Value
+		}
+	}
+	if len(u.Photos) > 0 {
+		return u.Photos[0].Value
+	}
+	return ""
 }
@@ -0,0 +1,27 @@
+package notion
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEmailOmitsUnsetAttributes(t *testing.T) {
+	tests := []struct {
+		email Email
+		want  string
+	}{
+		{Email{Value: "alice@example.com"}, `{"value":"alice@example.com"}`},
+		{Email{Value: "alice@example.com", Type: "work"}, `{"value":"alice@example.com","type":"work"}`},
+		{Email{Value: "alice@example.com", Type: "work", Primary: true}, `{"value":"alice@example.com","type":"work","primary":true}`},
+	}
+
+	for _, tt := range tests {
+		b, err := json.Marshal(tt.email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("got %s, want %s", b, tt.want)
+		}
+	}
+}
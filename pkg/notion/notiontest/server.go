@@ -0,0 +1,1140 @@
+// Package notiontest provides a fake Notion server for tests. It serves the
+// public API endpoints the connector uses for users, search and database rows,
+// and the SCIM
+// endpoints for users and groups, with paging, simple filters, creating,
+// replacing and deleting resources, membership changes, bulk requests and
+// injectable errors.
+package notiontest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	notionScim "github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/dstotijn/go-notion"
+)
+
+// Hosts served by the fake server. Requests to other hosts fail.
+const (
+	APIHost  = "api.notion.com"
+	SCIMHost = "www.notion.so"
+)
+
+const (
+	defaultPageSize = 100
+	// propertyLimit is the number of references Notion returns at most for a
+	// property of a page. Longer lists are cut off.
+	propertyLimit   = 25
+	scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// Server is a fake Notion server. It is safe for concurrent use.
+type Server struct {
+	server *httptest.Server
+
+	mu            sync.Mutex
+	users         []notion.User
+	scimUsers     []notionScim.User
+	groups        []notionScim.Group
+	userIndex     map[string]int
+	scimUserIndex map[string]int
+	groupIndex    map[string]int
+	searchResults []searchResult
+	// databaseRows are the rows of each database, by database ID.
+	databaseRows map[string][]notion.Page
+	failures     []*failure
+	requests     []string
+
+	// bulk is the bulk configuration of the server. Bulk requests are
+	// rejected unless it is supported.
+	bulk notionScim.BulkConfig
+	// lastID numbers the IDs of created SCIM resources.
+	lastID int
+}
+
+type searchResult struct {
+	object string
+	body   json.RawMessage
+}
+
+// failure makes matching requests fail with an error response.
+type failure struct {
+	method    string
+	path      string
+	status    int
+	code      string
+	remaining int
+}
+
+// NewServer starts a fake Notion server. Callers must call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		userIndex:     make(map[string]int),
+		scimUserIndex: make(map[string]int),
+		groupIndex:    make(map[string]int),
+		databaseRows:  make(map[string][]notion.Page),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/users", s.listUsers)
+	mux.HandleFunc("GET /v1/users/{id}", s.getUser)
+	mux.HandleFunc("POST /v1/search", s.search)
+	mux.HandleFunc("POST /v1/databases/{id}/query", s.queryDatabase)
+	mux.HandleFunc("GET /v1/pages/{id}/properties/{property}", s.getPageProperty)
+	mux.HandleFunc("GET /scim/v2/Users", s.listSCIMUsers)
+	mux.HandleFunc("POST /scim/v2/Users", s.createSCIMUser)
+	mux.HandleFunc("GET /scim/v2/Users/{id}", s.getSCIMUser)
+	mux.HandleFunc("PUT /scim/v2/Users/{id}", s.replaceSCIMUser)
+	mux.HandleFunc("PATCH /scim/v2/Users/{id}", s.patchSCIMUser)
+	mux.HandleFunc("DELETE /scim/v2/Users/{id}", s.deleteSCIMUser)
+	mux.HandleFunc("GET /scim/v2/Groups", s.listGroups)
+	mux.HandleFunc("POST /scim/v2/Groups", s.createGroup)
+	mux.HandleFunc("GET /scim/v2/Groups/{id}", s.getGroup)
+	mux.HandleFunc("PUT /scim/v2/Groups/{id}", s.replaceGroup)
+	mux.HandleFunc("PATCH /scim/v2/Groups/{id}", s.patchGroup)
+	mux.HandleFunc("DELETE /scim/v2/Groups/{id}", s.deleteGroup)
+	mux.HandleFunc("GET /scim/v2/ServiceProviderConfig", s.serviceProviderConfig)
+	mux.HandleFunc("POST /scim/v2/Bulk", s.bulkRequest)
+
+	s.server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// URL returns the base URL of the server.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Client returns an HTTP client that sends requests for the Notion public API
+// and SCIM hosts to the fake server.
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.server.URL)
+
+	return &http.Client{
+		Transport: &rewriteTransport{
+			target: target,
+			base:   s.server.Client().Transport,
+		},
+	}
+}
+
+type rewriteTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != APIHost && req.URL.Host != SCIMHost {
+		return nil, fmt.Errorf("notiontest: unexpected request to %s", req.URL.Host)
+	}
+
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	req.Host = t.target.Host
+
+	return t.base.RoundTrip(req)
+}
+
+// AddUsers adds users to the public API user list.
+func (s *Server) AddUsers(users ...notion.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range users {
+		s.userIndex[user.ID] = len(s.users)
+		s.users = append(s.users, user)
+	}
+}
+
+// AddSCIMUsers adds users to the SCIM user list, replacing those with the
+// same ID.
+func (s *Server) AddSCIMUsers(users ...notionScim.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range users {
+		if i, ok := s.scimUserIndex[user.ID]; ok {
+			s.scimUsers[i] = user
+			continue
+		}
+		s.scimUserIndex[user.ID] = len(s.scimUsers)
+		s.scimUsers = append(s.scimUsers, user)
+	}
+}
+
+// AddGroups adds SCIM groups, replacing those with the same ID.
+func (s *Server) AddGroups(groups ...notionScim.Group) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, group := range groups {
+		if i, ok := s.groupIndex[group.ID]; ok {
+			s.groups[i] = group
+			continue
+		}
+		s.groupIndex[group.ID] = len(s.groups)
+		s.groups = append(s.groups, group)
+	}
+}
+
+// EnableBulk makes the server support bulk requests of at most maxOperations
+// operations and maxPayloadSize bytes.
+func (s *Server) EnableBulk(maxOperations, maxPayloadSize int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bulk = notionScim.BulkConfig{
+		Supported:      true,
+		MaxOperations:  maxOperations,
+		MaxPayloadSize: maxPayloadSize,
+	}
+}
+
+// Group returns the SCIM group with the given ID, as changed by the requests
+// the server received.
+func (s *Server) Group(id string) (notionScim.Group, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.groupIndex[id]
+	if !ok {
+		return notionScim.Group{}, false
+	}
+	return s.groups[i], true
+}
+
+// AddPage adds a page to the search results. A non-empty publicURL marks the
+// page as published to the web.
+func (s *Server) AddPage(page notion.Page, publicURL string) error {
+	extra := map[string]interface{}{"public_url": nil}
+	if publicURL != "" {
+		extra["public_url"] = publicURL
+	}
+
+	return s.addSearchResult("page", page, extra)
+}
+
+// AddDatabase adds a database to the search results.
+func (s *Server) AddDatabase(db notion.Database) error {
+	return s.addSearchResult("database", db, nil)
+}
+
+// AddDatabaseRows adds rows to the database with the given ID. Querying the
+// database cuts off the People properties of the rows at 25 users, as Notion
+// does, while the page property endpoint returns all of them.
+func (s *Server) AddDatabaseRows(databaseID string, rows ...notion.Page) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.databaseRows[databaseID] = append(s.databaseRows[databaseID], rows...)
+}
+
+func (s *Server) addSearchResult(object string, v interface{}, extra map[string]interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	fields["object"] = object
+	for k, v := range extra {
+		fields[k] = v
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searchResults = append(s.searchResults, searchResult{object: object, body: body})
+	return nil
+}
+
+// Fail makes the next times requests with the given method and a path
+// starting with path fail with status. code is the Notion error code of
+// public API errors. A times of zero fails all matching requests.
+func (s *Server) Fail(method, path string, status int, code string, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{
+		method:    method,
+		path:      path,
+		status:    status,
+		code:      code,
+		remaining: times,
+	})
+}
+
+// Requests returns the requests the server received, as "METHOD /path".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		f := s.matchFailure(r)
+		s.mu.Unlock()
+
+		switch {
+		case f != nil:
+			writeError(w, r, f.status, f.code, "injected failure")
+		case !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "):
+			writeError(w, r, http.StatusUnauthorized, "unauthorized", "API token is invalid.")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// matchFailure returns the injected failure for a request. Callers must hold
+// s.mu.
+func (s *Server) matchFailure(r *http.Request) *failure {
+	for i, f := range s.failures {
+		if f.method != r.Method || !strings.HasPrefix(r.URL.Path, f.path) {
+			continue
+		}
+
+		if f.remaining > 0 {
+			f.remaining--
+			if f.remaining == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the format of the public API or of SCIM,
+// depending on the endpoint.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if strings.HasPrefix(r.URL.Path, "/scim/") {
+		writeSCIMError(w, status, "", message)
+		return
+	}
+
+	writeJSON(w, status, map[string]interface{}{
+		"object":  "error",
+		"status":  status,
+		"code":    code,
+		"message": message,
+	})
+}
+
+// writeSCIMError writes a SCIM error message, with a scimType when it isn't
+// empty.
+func writeSCIMError(w http.ResponseWriter, status int, scimType, detail string) {
+	body := map[string]interface{}{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	writeJSON(w, status, body)
+}
+
+// page returns the bounds of a page of n items starting at the cursor, and
+// the cursor of the next page if there is one.
+func page(cursor string, pageSize, n int) (int, int, *string, error) {
+	start := 0
+	if cursor != "" {
+		var err error
+		start, err = strconv.Atoi(cursor)
+		if err != nil || start < 0 || start > n {
+			return 0, 0, nil, fmt.Errorf("invalid cursor %q", cursor)
+		}
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	end := start + pageSize
+	if end >= n {
+		return start, n, nil, nil
+	}
+
+	next := strconv.Itoa(end)
+	return start, end, &next, nil
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+
+	s.mu.Lock()
+	start, end, next, err := page(r.URL.Query().Get("start_cursor"), pageSize, len(s.users))
+	var users []notion.User
+	if err == nil {
+		users = append(users, s.users[start:end]...)
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object":      "list",
+		"results":     users,
+		"has_more":    next != nil,
+		"next_cursor": next,
+	})
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, ok := s.userIndex[id]; ok {
+		writeJSON(w, http.StatusOK, s.users[i])
+		return
+	}
+	// "me" is the bot of the integration making the request.
+	if id == "me" {
+		for _, user := range s.users {
+			if user.Type == notion.UserTypeBot {
+				writeJSON(w, http.StatusOK, user)
+				return
+			}
+		}
+	}
+
+	writeError(w, r, http.StatusNotFound, "object_not_found", fmt.Sprintf("Could not find user with ID: %s.", id))
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Filter *struct {
+			Value string `json:"value"`
+		} `json:"filter"`
+		StartCursor string `json:"start_cursor"`
+		PageSize    int    `json:"page_size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	var results []json.RawMessage
+	for _, result := range s.searchResults {
+		if body.Filter == nil || body.Filter.Value == result.object {
+			results = append(results, result.body)
+		}
+	}
+	s.mu.Unlock()
+
+	start, end, next, err := page(body.StartCursor, body.PageSize, len(results))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object":      "list",
+		"results":     append([]json.RawMessage{}, results[start:end]...),
+		"has_more":    next != nil,
+		"next_cursor": next,
+	})
+}
+
+// truncateProperties returns the properties of a row with People properties
+// cut off at propertyLimit users.
+func truncateProperties(props notion.DatabasePageProperties) notion.DatabasePageProperties {
+	rv := make(notion.DatabasePageProperties, len(props))
+	for name, prop := range props {
+		if len(prop.People) > propertyLimit {
+			prop.People = prop.People[:propertyLimit]
+		}
+		rv[name] = prop
+	}
+	return rv
+}
+
+func (s *Server) queryDatabase(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		StartCursor string `json:"start_cursor"`
+		PageSize    int    `json:"page_size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	rows, ok := s.databaseRows[r.PathValue("id")]
+	rows = slices.Clone(rows)
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "object_not_found", fmt.Sprintf("Could not find database with ID: %s.", r.PathValue("id")))
+		return
+	}
+	start, end, next, err := page(body.StartCursor, body.PageSize, len(rows))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	results := rows[start:end]
+	for i, row := range results {
+		if props, ok := row.Properties.(notion.DatabasePageProperties); ok {
+			results[i].Properties = truncateProperties(props)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object":      "list",
+		"results":     results,
+		"has_more":    next != nil,
+		"next_cursor": next,
+	})
+}
+
+// getPageProperty serves the People properties of database rows, one user
+// per property item.
+func (s *Server) getPageProperty(w http.ResponseWriter, r *http.Request) {
+	pageID, propID := r.PathValue("id"), r.PathValue("property")
+
+	s.mu.Lock()
+	var people []notion.User
+	found := false
+	for _, rows := range s.databaseRows {
+		for _, row := range rows {
+			props, ok := row.Properties.(notion.DatabasePageProperties)
+			if !ok || row.ID != pageID {
+				continue
+			}
+			for _, prop := range props {
+				if prop.ID == propID && prop.Type == notion.DBPropTypePeople {
+					people, found = prop.People, true
+				}
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, r, http.StatusNotFound, "object_not_found", fmt.Sprintf("Could not find property with ID: %s.", propID))
+		return
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	start, end, next, err := page(r.URL.Query().Get("start_cursor"), pageSize, len(people))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	items := make([]map[string]interface{}, 0, end-start)
+	for _, person := range people[start:end] {
+		items = append(items, map[string]interface{}{
+			"object": "property_item",
+			"id":     propID,
+			"type":   notion.DBPropTypePeople,
+			"people": person,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object":        "list",
+		"results":       items,
+		"has_more":      next != nil,
+		"next_cursor":   next,
+		"type":          "property_item",
+		"property_item": map[string]interface{}{"id": propID, "type": notion.DBPropTypePeople, "people": map[string]interface{}{}},
+	})
+}
+
+// scimPage returns the bounds of a page of n items for the 1-based startIndex
+// and count query parameters.
+func scimPage(r *http.Request, n int) (int, int) {
+	startIndex, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count <= 0 {
+		count = defaultPageSize
+	}
+
+	start := startIndex - 1
+	if start > n {
+		start = n
+	}
+	end := start + count
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+// onlyIDs returns the IDs of resources as resources of the id attribute
+// alone, for list requests with attributes=id. Other attributes parameters
+// are ignored, as servers may do.
+func onlyIDs(ids []string) []map[string]string {
+	rv := make([]map[string]string, 0, len(ids))
+	for _, id := range ids {
+		rv = append(rv, map[string]string{"id": id})
+	}
+	return rv
+}
+
+func scimList(start, end, total int, resources interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"schemas":      []string{"urn:ietf:params:scim:api:messages:2.0:ListResponse"},
+		"totalResults": total,
+		"startIndex":   start + 1,
+		"itemsPerPage": end - start,
+		"Resources":    resources,
+	}
+}
+
+// scimFilter is the filter of a SCIM list request. The fake server only
+// supports a single comparison with a string: eq, such as userName eq "a@b.c",
+// or gt on meta.lastModified.
+type scimFilter struct {
+	attr  string
+	op    string
+	value string
+}
+
+func parseSCIMFilter(r *http.Request) (scimFilter, error) {
+	filter := r.URL.Query().Get("filter")
+	if filter == "" {
+		return scimFilter{}, nil
+	}
+
+	parts := strings.SplitN(filter, " ", 3)
+	if len(parts) != 3 || strings.ContainsAny(parts[0], "()") {
+		return scimFilter{}, fmt.Errorf("unsupported filter %q", filter)
+	}
+	f := scimFilter{attr: parts[0], op: parts[1]}
+	if (f.op == "gt") != (f.attr == "meta.lastModified") || (f.op != "eq" && f.op != "gt") {
+		return scimFilter{}, fmt.Errorf("unsupported filter %q", filter)
+	}
+	if err := json.Unmarshal([]byte(parts[2]), &f.value); err != nil {
+		return scimFilter{}, fmt.Errorf("unsupported filter %q", filter)
+	}
+	return f, nil
+}
+
+// modifiedAfter reports whether a resource with meta was modified after the
+// time value. Resources without metadata never are.
+func modifiedAfter(meta *notionScim.Meta, value string) (bool, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false, fmt.Errorf("invalid time %q", value)
+	}
+	return meta != nil && meta.LastModified.After(t), nil
+}
+
+func scimUserMatches(user notionScim.User, f scimFilter) (bool, error) {
+	switch f.attr {
+	case "meta.lastModified":
+		return modifiedAfter(user.Meta, f.value)
+	case "userName":
+		return strings.EqualFold(user.UserName, f.value), nil
+	case "email":
+		for _, email := range user.Emails {
+			if strings.EqualFold(email.Value, f.value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported filter attribute %q", f.attr)
+	}
+}
+
+func scimGroupMatches(group notionScim.Group, f scimFilter) (bool, error) {
+	switch f.attr {
+	case "meta.lastModified":
+		return modifiedAfter(group.Meta, f.value)
+	case "displayName":
+		return group.DisplayName == f.value, nil
+	case "members.value":
+		return slices.ContainsFunc(group.Members, func(m notionScim.Member) bool { return m.Value == f.value }), nil
+	default:
+		return false, fmt.Errorf("unsupported filter attribute %q", f.attr)
+	}
+}
+
+func (s *Server) listSCIMUsers(w http.ResponseWriter, r *http.Request) {
+	f, err := parseSCIMFilter(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	users := s.scimUsers
+	if f.attr != "" {
+		users = nil
+		for _, user := range s.scimUsers {
+			ok, err := scimUserMatches(user, f)
+			if err != nil {
+				s.mu.Unlock()
+				writeError(w, r, http.StatusBadRequest, "", err.Error())
+				return
+			}
+			if ok {
+				users = append(users, user)
+			}
+		}
+	}
+	total := len(users)
+	start, end := scimPage(r, total)
+	users = append([]notionScim.User{}, users[start:end]...)
+	s.mu.Unlock()
+
+	if r.URL.Query().Get("attributes") == "id" {
+		ids := make([]string, 0, len(users))
+		for _, user := range users {
+			ids = append(ids, user.ID)
+		}
+		writeJSON(w, http.StatusOK, scimList(start, end, total, onlyIDs(ids)))
+		return
+	}
+	writeJSON(w, http.StatusOK, scimList(start, end, total, users))
+}
+
+func (s *Server) getSCIMUser(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, ok := s.scimUserIndex[id]; ok {
+		writeJSON(w, http.StatusOK, s.scimUsers[i])
+		return
+	}
+
+	writeError(w, r, http.StatusNotFound, "", fmt.Sprintf("User %s not found", id))
+}
+
+// decodeResource decodes the body of a request that creates or replaces a
+// resource, and writes an error response when it can't.
+func decodeResource(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return false
+	}
+	return true
+}
+
+// newID returns the ID of a created resource. Callers must hold s.mu.
+func (s *Server) newID(prefix string) string {
+	s.lastID++
+	return fmt.Sprintf("%s-%04d", prefix, s.lastID)
+}
+
+// userNameTaken reports whether a SCIM user other than id has userName.
+// Callers must hold s.mu.
+func (s *Server) userNameTaken(userName, id string) bool {
+	return slices.ContainsFunc(s.scimUsers, func(u notionScim.User) bool {
+		return u.ID != id && strings.EqualFold(u.UserName, userName)
+	})
+}
+
+func (s *Server) createSCIMUser(w http.ResponseWriter, r *http.Request) {
+	var user notionScim.User
+	if !decodeResource(w, r, &user) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userNameTaken(user.UserName, "") {
+		writeSCIMError(w, http.StatusConflict, "uniqueness", fmt.Sprintf("User %s already exists", user.UserName))
+		return
+	}
+	user.ID = s.newID("scim-user")
+	s.scimUserIndex[user.ID] = len(s.scimUsers)
+	s.scimUsers = append(s.scimUsers, user)
+
+	writeJSON(w, http.StatusCreated, user)
+}
+
+func (s *Server) replaceSCIMUser(w http.ResponseWriter, r *http.Request) {
+	var user notionScim.User
+	if !decodeResource(w, r, &user) {
+		return
+	}
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.scimUserIndex[id]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "", fmt.Sprintf("User %s not found", id))
+		return
+	}
+	if s.userNameTaken(user.UserName, id) {
+		writeSCIMError(w, http.StatusConflict, "uniqueness", fmt.Sprintf("User %s already exists", user.UserName))
+		return
+	}
+	user.ID = id
+	s.scimUsers[i] = user
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// patchSCIMUser applies the operations of a PATCH request to a user. The
+// fake server only supports replacing active.
+func (s *Server) patchSCIMUser(w http.ResponseWriter, r *http.Request) {
+	var patch struct {
+		Operations []patchOperation `json:"Operations"`
+	}
+	if !decodeResource(w, r, &patch) {
+		return
+	}
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.scimUserIndex[id]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "", fmt.Sprintf("User %s not found", id))
+		return
+	}
+	user := s.scimUsers[i]
+
+	for _, op := range patch.Operations {
+		if op.Op != notionScim.PatchReplace || op.Path != "active" {
+			writeSCIMError(w, http.StatusBadRequest, "invalidPath", fmt.Sprintf("unsupported operation %s on %q", op.Op, op.Path))
+			return
+		}
+		if err := json.Unmarshal(op.Value, &user.Active); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+	}
+
+	s.scimUsers[i] = user
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteSCIMUser(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.scimUserIndex[id]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "", fmt.Sprintf("User %s not found", id))
+		return
+	}
+	s.scimUsers = slices.Delete(s.scimUsers, i, i+1)
+	s.scimUserIndex = make(map[string]int, len(s.scimUsers))
+	for i, user := range s.scimUsers {
+		s.scimUserIndex[user.ID] = i
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request) {
+	f, err := parseSCIMFilter(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	groups := s.groups
+	if f.attr != "" {
+		groups = nil
+		for _, group := range s.groups {
+			ok, err := scimGroupMatches(group, f)
+			if err != nil {
+				s.mu.Unlock()
+				writeError(w, r, http.StatusBadRequest, "", err.Error())
+				return
+			}
+			if ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+	total := len(groups)
+	start, end := scimPage(r, total)
+	groups = append([]notionScim.Group{}, groups[start:end]...)
+	s.mu.Unlock()
+
+	if r.URL.Query().Get("attributes") == "id" {
+		ids := make([]string, 0, len(groups))
+		for _, group := range groups {
+			ids = append(ids, group.ID)
+		}
+		writeJSON(w, http.StatusOK, scimList(start, end, total, onlyIDs(ids)))
+		return
+	}
+	writeJSON(w, http.StatusOK, scimList(start, end, total, groups))
+}
+
+func (s *Server) getGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, ok := s.groupIndex[id]; ok {
+		writeJSON(w, http.StatusOK, s.groups[i])
+		return
+	}
+
+	writeError(w, r, http.StatusNotFound, "", fmt.Sprintf("Group %s not found", id))
+}
+
+// displayNameTaken reports whether a group other than id has displayName.
+// Callers must hold s.mu.
+func (s *Server) displayNameTaken(displayName, id string) bool {
+	return slices.ContainsFunc(s.groups, func(g notionScim.Group) bool {
+		return g.ID != id && g.DisplayName == displayName
+	})
+}
+
+func (s *Server) createGroup(w http.ResponseWriter, r *http.Request) {
+	var group notionScim.Group
+	if !decodeResource(w, r, &group) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.displayNameTaken(group.DisplayName, "") {
+		writeSCIMError(w, http.StatusConflict, "uniqueness", fmt.Sprintf("Group %s already exists", group.DisplayName))
+		return
+	}
+	group.ID = s.newID("scim-group")
+	s.groupIndex[group.ID] = len(s.groups)
+	s.groups = append(s.groups, group)
+
+	writeJSON(w, http.StatusCreated, group)
+}
+
+func (s *Server) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	var group notionScim.Group
+	if !decodeResource(w, r, &group) {
+		return
+	}
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.groupIndex[id]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "", fmt.Sprintf("Group %s not found", id))
+		return
+	}
+	if s.displayNameTaken(group.DisplayName, id) {
+		writeSCIMError(w, http.StatusConflict, "uniqueness", fmt.Sprintf("Group %s already exists", group.DisplayName))
+		return
+	}
+	group.ID = id
+	s.groups[i] = group
+
+	writeJSON(w, http.StatusOK, group)
+}
+
+func (s *Server) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.groupIndex[id]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "", fmt.Sprintf("Group %s not found", id))
+		return
+	}
+	s.groups = slices.Delete(s.groups, i, i+1)
+	s.groupIndex = make(map[string]int, len(s.groups))
+	for i, group := range s.groups {
+		s.groupIndex[group.ID] = i
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// patchOperation is an operation of a PATCH request, with its value left
+// encoded until the path tells its type.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyGroupPatch applies the operations of a PATCH request to a group. The
+// fake server supports adding and removing members, and renaming groups. It
+// returns the status of the response.
+func (s *Server) applyGroupPatch(id string, body []byte) (int, error) {
+	var patch struct {
+		Operations []patchOperation `json:"Operations"`
+	}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.groupIndex[id]
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("Group %s not found", id)
+	}
+	group := s.groups[i]
+	group.Members = append([]notionScim.Member(nil), group.Members...)
+
+	for _, op := range patch.Operations {
+		switch {
+		case op.Op == notionScim.PatchAdd && op.Path == "members":
+			var members []notionScim.Member
+			if err := json.Unmarshal(op.Value, &members); err != nil {
+				return http.StatusBadRequest, err
+			}
+			for _, member := range members {
+				if !slices.ContainsFunc(group.Members, func(m notionScim.Member) bool { return m.Value == member.Value }) {
+					group.Members = append(group.Members, member)
+				}
+			}
+		case op.Op == notionScim.PatchRemove && strings.HasPrefix(op.Path, "members[value eq ") && strings.HasSuffix(op.Path, "]"):
+			var value string
+			if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(op.Path, "members[value eq "), "]")), &value); err != nil {
+				return http.StatusBadRequest, fmt.Errorf("unsupported path %q", op.Path)
+			}
+			group.Members = slices.DeleteFunc(group.Members, func(m notionScim.Member) bool { return m.Value == value })
+		case op.Op == notionScim.PatchReplace && op.Path == "displayName":
+			if err := json.Unmarshal(op.Value, &group.DisplayName); err != nil {
+				return http.StatusBadRequest, err
+			}
+		default:
+			return http.StatusBadRequest, fmt.Errorf("unsupported operation %s on %q", op.Op, op.Path)
+		}
+	}
+
+	s.groups[i] = group
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) patchGroup(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "", err.Error())
+		return
+	}
+
+	status, err := s.applyGroupPatch(r.PathValue("id"), body)
+	if err != nil {
+		writeError(w, r, status, "", err.Error())
+		return
+	}
+	w.WriteHeader(status)
+}
+
+func (s *Server) serviceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	bulk := s.bulk
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, notionScim.ServiceProviderConfig{
+		Schemas: []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		Patch:   notionScim.Supported{Supported: true},
+		Bulk:    bulk,
+		Filter:  notionScim.FilterConfig{Supported: true, MaxResults: defaultPageSize},
+	})
+}
+
+// bulkRequest runs the operations of a bulk request. Only PATCH operations
+// on groups are supported.
+func (s *Server) bulkRequest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	bulk := s.bulk
+	s.mu.Unlock()
+
+	if !bulk.Supported {
+		writeError(w, r, http.StatusNotImplemented, "", "Bulk requests are not supported")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	if bulk.MaxPayloadSize > 0 && len(body) > bulk.MaxPayloadSize {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "", fmt.Sprintf("The size of the bulk operation exceeds the maxPayloadSize (%d).", bulk.MaxPayloadSize))
+		return
+	}
+
+	var req struct {
+		Operations []struct {
+			Method string          `json:"method"`
+			BulkID string          `json:"bulkId"`
+			Path   string          `json:"path"`
+			Data   json.RawMessage `json:"data"`
+		} `json:"Operations"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	if bulk.MaxOperations > 0 && len(req.Operations) > bulk.MaxOperations {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "", fmt.Sprintf("The number of operations exceeds the maxOperations (%d).", bulk.MaxOperations))
+		return
+	}
+
+	results := make([]notionScim.BulkOperationResult, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		result := notionScim.BulkOperationResult{
+			Method:   op.Method,
+			BulkID:   op.BulkID,
+			Location: s.server.URL + "/scim/v2" + op.Path,
+		}
+
+		status := http.StatusBadRequest
+		err := fmt.Errorf("unsupported operation %s %s", op.Method, op.Path)
+		if id, ok := strings.CutPrefix(op.Path, "/Groups/"); ok && op.Method == http.MethodPatch {
+			status, err = s.applyGroupPatch(id, op.Data)
+		}
+
+		result.Status = notionScim.BulkStatus(status)
+		if err != nil {
+			result.Response, _ = json.Marshal(map[string]interface{}{
+				"schemas": []string{scimErrorSchema},
+				"status":  strconv.Itoa(status),
+				"detail":  err.Error(),
+			})
+		}
+		results = append(results, result)
+	}
+
+	writeJSON(w, http.StatusOK, notionScim.BulkResponse{
+		Schemas:    []string{notionScim.BulkResponseSchema},
+		Operations: results,
+	})
+}
@@ -0,0 +1,132 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	gonotion "github.com/dstotijn/go-notion"
+)
+
+const (
+	apiBaseUrl = "https://api.notion.com/v1"
+	apiVersion = "2022-06-28"
+)
+
+// APIBaseURL is the base URL of the Notion public API.
+const APIBaseURL = apiBaseUrl
+
+// APIClient makes the Notion public API requests that go-notion doesn't
+// support.
+type APIClient struct {
+	httpClient *http.Client
+	apiKey     string
+}
+
+func NewAPIClient(apiKey string, httpClient *http.Client) *APIClient {
+	return &APIClient{
+		httpClient: httpClient,
+		apiKey:     apiKey,
+	}
+}
+
+// SharedPage is a page together with the sharing fields go-notion doesn't
+// decode.
+type SharedPage struct {
+	gonotion.Page
+
+	// PublicURL is set when the page is published to the web.
+	PublicURL *string
+}
+
+type SharedPagesResponse struct {
+	Results    []SharedPage
+	HasMore    bool
+	NextCursor *string
+}
+
+// SearchPages returns one page of the pages shared with the integration.
+func (c *APIClient) SearchPages(ctx context.Context, startCursor string, pageSize int) (SharedPagesResponse, error) {
+	body := map[string]interface{}{
+		"filter": map[string]string{
+			"property": "object",
+			"value":    "page",
+		},
+		"page_size": pageSize,
+	}
+	if startCursor != "" {
+		body["start_cursor"] = startCursor
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return SharedPagesResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprint(apiBaseUrl, "/search"), bytes.NewReader(payload))
+	if err != nil {
+		return SharedPagesResponse{}, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	var res struct {
+		Results    []json.RawMessage `json:"results"`
+		HasMore    bool              `json:"has_more"`
+		NextCursor *string           `json:"next_cursor"`
+	}
+	if err := c.doRequest(req, &res); err != nil {
+		return SharedPagesResponse{}, err
+	}
+
+	rv := SharedPagesResponse{
+		HasMore:    res.HasMore,
+		NextCursor: res.NextCursor,
+	}
+	for _, raw := range res.Results {
+		var page SharedPage
+		if err := json.Unmarshal(raw, &page.Page); err != nil {
+			return SharedPagesResponse{}, err
+		}
+
+		var sharing struct {
+			PublicURL *string `json:"public_url"`
+		}
+		if err := json.Unmarshal(raw, &sharing); err != nil {
+			return SharedPagesResponse{}, err
+		}
+		page.PublicURL = sharing.PublicURL
+
+		rv.Results = append(rv.Results, page)
+	}
+
+	return rv, nil
+}
+
+// doRequest sends a public API request. Error responses are returned as
+// *gonotion.APIError, like go-notion does.
+func (c *APIClient) doRequest(req *http.Request, resType interface{}) error {
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	req.Header.Add("Notion-Version", apiVersion)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr gonotion.APIError
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
+			return fmt.Errorf("notion-connector: %s %s returned status %d", req.Method, req.URL.Path, resp.StatusCode)
+		}
+		return &apiErr
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(resType); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package notion
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The operations of a SCIM PATCH request, as defined by RFC 7644, section
+// 3.5.2.
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+)
+
+// Path is the target of a PATCH operation: an attribute, or the values of a
+// multi-valued attribute that match a filter, optionally narrowed down to one
+// of their sub-attributes.
+type Path struct {
+	attr   string
+	filter string
+	sub    string
+}
+
+// AttrPath returns the path of an attribute, such as "active",
+// "name.givenName", or an attribute of an extension prefixed by the URN of its
+// schema.
+func AttrPath(attr string) Path {
+	return Path{attr: attr}
+}
+
+// FilterPath returns the path of the values of the multi-valued attribute attr
+// that match filter, such as emails[type eq "work" and value ew "example.com"].
+func FilterPath(attr string, filter Filter) Path {
+	return Path{attr: attr, filter: filter.String()}
+}
+
+// ValuePath returns the path of the values of the multi-valued attribute attr
+// whose sub-attribute subAttr equals value, such as members[value eq "id"].
+func ValuePath(attr string, subAttr string, value interface{}) Path {
+	return FilterPath(attr, Eq(subAttr, value))
+}
+
+// Sub returns the path of a sub-attribute of the values p targets.
+func (p Path) Sub(subAttr string) Path {
+	p.sub = subAttr
+	return p
+}
+
+func (p Path) String() string {
+	s := p.attr
+	if p.filter != "" {
+		s += "[" + p.filter + "]"
+	}
+	if p.sub != "" {
+		s += "." + p.sub
+	}
+	return s
+}
+
+// PatchBuilder builds a PatchOp one operation at a time. Invalid operations
+// are reported by Build.
+type PatchBuilder struct {
+	operations []PatchOperation
+	err        error
+}
+
+// NewPatch returns an empty PatchBuilder.
+func NewPatch() *PatchBuilder {
+	return &PatchBuilder{}
+}
+
+// Add adds value to the attribute at path. Values of multi-valued attributes
+// are added to the existing ones.
+func (b *PatchBuilder) Add(path Path, value interface{}) *PatchBuilder {
+	return b.op(PatchAdd, path, value)
+}
+
+// AddAttributes adds the attributes of value, an object keyed by attribute
+// name, to the resource.
+func (b *PatchBuilder) AddAttributes(value interface{}) *PatchBuilder {
+	return b.op(PatchAdd, Path{}, value)
+}
+
+// Remove removes the attribute, or the values, at path.
+func (b *PatchBuilder) Remove(path Path) *PatchBuilder {
+	if path.attr == "" {
+		b.fail(errors.New("notion-connector: remove operations need a path"))
+		return b
+	}
+
+	b.operations = append(b.operations, PatchOperation{
+		Op:   PatchRemove,
+		Path: path.String(),
+	})
+	return b
+}
+
+// Replace replaces the attribute, or the values, at path with value.
+func (b *PatchBuilder) Replace(path Path, value interface{}) *PatchBuilder {
+	return b.op(PatchReplace, path, value)
+}
+
+// ReplaceAttributes replaces the attributes of the resource with those of
+// value, an object keyed by attribute name.
+func (b *PatchBuilder) ReplaceAttributes(value interface{}) *PatchBuilder {
+	return b.op(PatchReplace, Path{}, value)
+}
+
+func (b *PatchBuilder) op(op string, path Path, value interface{}) *PatchBuilder {
+	if value == nil {
+		b.fail(fmt.Errorf("notion-connector: %s operations need a value", op))
+		return b
+	}
+	if path.attr == "" && (path.filter != "" || path.sub != "") {
+		b.fail(fmt.Errorf("notion-connector: invalid path %q", path.String()))
+		return b
+	}
+
+	b.operations = append(b.operations, PatchOperation{
+		Op:    op,
+		Path:  path.String(),
+		Value: value,
+	})
+	return b
+}
+
+func (b *PatchBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Build returns the PatchOp of the operations added so far, or the error of
+// the first invalid one.
+func (b *PatchBuilder) Build() (PatchOp, error) {
+	if b.err != nil {
+		return PatchOp{}, b.err
+	}
+	if len(b.operations) == 0 {
+		return PatchOp{}, errors.New("notion-connector: a PATCH request needs at least one operation")
+	}
+
+	return NewPatchOp(b.operations...), nil
+}
@@ -0,0 +1,253 @@
+package notion
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const (
+	babsID  = "2819c223-7f76-453a-919d-413861904646"
+	babsRef = "https://example.com/v2/Users/2819c223-7f76-453a-919d-413861904646"
+)
+
+// The examples of RFC 7644, section 3.5.2.
+func TestPatchBuilderRFC7644(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch *PatchBuilder
+		want  string
+	}{
+		{
+			name: "add a member",
+			patch: NewPatch().Add(AttrPath("members"), []Member{
+				{Display: "Babs Jensen", Ref: babsRef, Value: babsID},
+			}),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{
+					"op": "add",
+					"path": "members",
+					"value": [{
+						"display": "Babs Jensen",
+						"$ref": "https://example.com/v2/Users/2819c223-7f76-453a-919d-413861904646",
+						"value": "2819c223-7f76-453a-919d-413861904646"
+					}]
+				}]
+			}`,
+		},
+		{
+			name: "add attributes without a path",
+			patch: NewPatch().AddAttributes(map[string]interface{}{
+				"emails":   []Email{{Value: "babs@jensen.org", Type: "home"}},
+				"nickname": "Babs",
+			}),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{
+					"op": "add",
+					"value": {
+						"emails": [{"value": "babs@jensen.org", "type": "home"}],
+						"nickname": "Babs"
+					}
+				}]
+			}`,
+		},
+		{
+			name:  "remove a single member",
+			patch: NewPatch().Remove(ValuePath("members", "value", babsID)),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{
+					"op": "remove",
+					"path": "members[value eq \"2819c223-7f76-453a-919d-413861904646\"]"
+				}]
+			}`,
+		},
+		{
+			name:  "remove all members",
+			patch: NewPatch().Remove(AttrPath("members")),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{"op": "remove", "path": "members"}]
+			}`,
+		},
+		{
+			name:  "remove values matching a filter",
+			patch: NewPatch().Remove(FilterPath("emails", And(Eq("type", "work"), Ew("value", "example.com")))),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{
+					"op": "remove",
+					"path": "emails[type eq \"work\" and value ew \"example.com\"]"
+				}]
+			}`,
+		},
+		{
+			name: "remove members and add others",
+			patch: NewPatch().
+				Remove(ValuePath("members", "value", babsID)).
+				Add(AttrPath("members"), []Member{
+					{Display: "James Smith", Ref: "https://example.com/v2/Users/08e1d05d-121c-4561-8b96-473d93df9210", Value: "08e1d05d-121c-4561-8b96-473d93df9210"},
+				}),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [
+					{
+						"op": "remove",
+						"path": "members[value eq \"2819c223-7f76-453a-919d-413861904646\"]"
+					},
+					{
+						"op": "add",
+						"path": "members",
+						"value": [{
+							"display": "James Smith",
+							"$ref": "https://example.com/v2/Users/08e1d05d-121c-4561-8b96-473d93df9210",
+							"value": "08e1d05d-121c-4561-8b96-473d93df9210"
+						}]
+					}
+				]
+			}`,
+		},
+		{
+			name: "replace all members",
+			patch: NewPatch().Replace(AttrPath("members"), []Member{
+				{Display: "Babs Jensen", Ref: babsRef, Value: babsID},
+			}),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{
+					"op": "replace",
+					"path": "members",
+					"value": [{
+						"display": "Babs Jensen",
+						"$ref": "https://example.com/v2/Users/2819c223-7f76-453a-919d-413861904646",
+						"value": "2819c223-7f76-453a-919d-413861904646"
+					}]
+				}]
+			}`,
+		},
+		{
+			name:  "replace a sub-attribute of matching values",
+			patch: NewPatch().Replace(ValuePath("addresses", "type", "work").Sub("streetAddress"), "1010 Broadway Ave"),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{
+					"op": "replace",
+					"path": "addresses[type eq \"work\"].streetAddress",
+					"value": "1010 Broadway Ave"
+				}]
+			}`,
+		},
+		{
+			name: "replace attributes without a path",
+			patch: NewPatch().ReplaceAttributes(map[string]interface{}{
+				"emails":   []Email{{Value: "bjensen@example.com", Type: "work", Primary: true}},
+				"nickname": "Babs",
+			}),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{
+					"op": "replace",
+					"value": {
+						"emails": [{"value": "bjensen@example.com", "type": "work", "primary": true}],
+						"nickname": "Babs"
+					}
+				}]
+			}`,
+		},
+		{
+			name:  "replace an attribute of an extension",
+			patch: NewPatch().Replace(AttrPath(EnterpriseUserSchema+":employeeNumber"), "701984"),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{
+					"op": "replace",
+					"path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber",
+					"value": "701984"
+				}]
+			}`,
+		},
+		{
+			name:  "deactivate a user",
+			patch: NewPatch().Replace(AttrPath("active"), false),
+			want: `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{"op": "replace", "path": "active", "value": false}]
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := tt.patch.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := json.Marshal(patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, got, []byte(tt.want))
+		})
+	}
+}
+
+func TestValuePathQuotesValues(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: `a "quoted" id`, want: `members[value eq "a \"quoted\" id"]`},
+		{value: `back\slash`, want: `members[value eq "back\\slash"]`},
+		{value: 42, want: `members[value eq 42]`},
+		{value: true, want: `members[value eq true]`},
+	}
+
+	for _, tt := range tests {
+		if got := ValuePath("members", "value", tt.value).String(); got != tt.want {
+			t.Errorf("ValuePath(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPatchBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch *PatchBuilder
+	}{
+		{name: "no operations", patch: NewPatch()},
+		{name: "remove without a path", patch: NewPatch().Remove(Path{})},
+		{name: "add without a value", patch: NewPatch().Add(AttrPath("members"), nil)},
+		{name: "replace without a value", patch: NewPatch().Replace(AttrPath("active"), nil)},
+		{name: "filter without an attribute", patch: NewPatch().Replace(FilterPath("", Eq("type", "work")), "x")},
+		{
+			name:  "invalid operation among valid ones",
+			patch: NewPatch().Replace(AttrPath("active"), true).Remove(Path{}).Add(AttrPath("title"), "x"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.patch.Build(); err == nil {
+				t.Error("Build succeeded, want an error")
+			}
+		})
+	}
+}
+
+func assertJSONEqual(t *testing.T, got, want []byte) {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultRequestsPerSecond is the average rate Notion allows per integration.
+const DefaultRequestsPerSecond = 3
+
+// RateLimiter spaces out requests evenly so that no more than a fixed number
+// are sent per second, however many goroutines send them.
+type RateLimiter struct {
+	clock Clock
+
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter returns a RateLimiter that tells time by clock, or by the
+// system clock when clock is nil. A requestsPerSecond of zero or less doesn't
+// limit requests.
+func NewRateLimiter(requestsPerSecond int, clock Clock) *RateLimiter {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	var interval time.Duration
+	if requestsPerSecond > 0 {
+		interval = time.Second / time.Duration(requestsPerSecond)
+	}
+
+	return &RateLimiter{
+		clock:    clock,
+		interval: interval,
+	}
+}
+
+// Wait blocks until the next request may be sent or the context is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := l.clock.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := at.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.clock.After(delay):
+		return nil
+	}
+}
+
+// Transport returns a transport that waits for the limiter before every
+// request it sends through base.
+func (l *RateLimiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &rateLimitedTransport{
+		base:    base,
+		limiter: l,
+	}
+}
+
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,62 @@
+package notion
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitClock stays at a fixed time and records the delays waited for.
+type waitClock struct {
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *waitClock) Now() time.Time {
+	return c.now
+}
+
+func (c *waitClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now.Add(d)
+	return ch
+}
+
+func TestRateLimiterSpacesRequests(t *testing.T) {
+	clock := &waitClock{now: time.Date(2024, 5, 13, 12, 0, 0, 0, time.UTC)}
+	limiter := NewRateLimiter(4, clock)
+
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond}
+	if len(clock.delays) != len(want) {
+		t.Fatalf("got delays %v, want %v", clock.delays, want)
+	}
+	for i := range want {
+		if clock.delays[i] != want[i] {
+			t.Errorf("got delays %v, want %v", clock.delays, want)
+			break
+		}
+	}
+}
+
+func TestRateLimiterWithoutLimit(t *testing.T) {
+	for _, requestsPerSecond := range []int{0, -1} {
+		clock := &waitClock{now: time.Date(2024, 5, 13, 12, 0, 0, 0, time.UTC)}
+		limiter := NewRateLimiter(requestsPerSecond, clock)
+
+		for i := 0; i < 10; i++ {
+			if err := limiter.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(clock.delays) != 0 {
+			t.Errorf("got delays %v with %d requests per second, want none", clock.delays, requestsPerSecond)
+		}
+	}
+}
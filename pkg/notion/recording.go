@@ -0,0 +1,280 @@
+package notion
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redactedSecret replaces API keys and tokens in recordings.
+const redactedSecret = "REDACTED"
+
+// emailPattern matches email addresses, also when the @ is URL encoded.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+(@|%40)[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// redactedLocalPattern matches the local parts redact writes, which are left
+// as they are so that redacting twice changes nothing.
+var redactedLocalPattern = regexp.MustCompile(`^redacted-[0-9a-f]{8}$`)
+
+// Recording is a request and its response, as stored by a Recorder.
+type Recording struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	RequestBody  string      `json:"request_body,omitempty"`
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header,omitempty"`
+	ResponseBody string      `json:"response_body,omitempty"`
+}
+
+func (r *Recording) key() string {
+	return recordingKey(r.Method, r.URL, r.RequestBody)
+}
+
+func recordingKey(method string, reqURL string, body string) string {
+	return method + " " + reqURL + "\n" + body
+}
+
+// redact replaces the secrets in s, and the local part of the email
+// addresses in s by a hash of the address. The same address is always
+// replaced the same way, and its domain is kept, so that recordings still
+// match up and domain based options behave the same on replay. Addresses
+// that are already redacted are kept, so that requests made with them on
+// replay match their recordings.
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redactedSecret)
+		}
+	}
+
+	return emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		at := strings.LastIndex(email, "@")
+		sep := "@"
+		if at < 0 {
+			at = strings.LastIndex(email, "%40")
+			sep = "%40"
+		}
+
+		if redactedLocalPattern.MatchString(email[:at]) {
+			return email
+		}
+
+		sum := sha256.Sum256([]byte(strings.ToLower(email[:at])))
+		return "redacted-" + hex.EncodeToString(sum[:4]) + sep + email[at+len(sep):]
+	})
+}
+
+// redactURL redacts the path and each query value of u as decoded, so that
+// an email address in a SCIM filter is told apart from the encoded quotes and
+// spaces around it, and is redacted the same way as in a body.
+func redactURL(u *url.URL, secrets []string) string {
+	redacted := *u
+	redacted.Path = redact(u.Path, secrets)
+	redacted.RawPath = ""
+
+	query := u.Query()
+	for _, values := range query {
+		for i, v := range values {
+			values[i] = redact(v, secrets)
+		}
+	}
+	redacted.RawQuery = query.Encode()
+
+	return redacted.String()
+}
+
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// Recorder is a transport that writes every request it sends, and the
+// response to it, to a file in a directory. Authorization headers aren't
+// recorded, and secrets and email addresses are redacted. The directory must
+// be empty, so that the files of one run are never mixed with, or
+// overwritten by, those of another.
+type Recorder struct {
+	base    http.RoundTripper
+	dir     string
+	secrets []string
+
+	mu sync.Mutex
+	n  int
+}
+
+// NewRecorder returns a Recorder that sends requests through base and writes
+// them to dir, which is created if needed. It fails when dir isn't empty.
+func NewRecorder(dir string, base http.RoundTripper, secrets ...string) (*Recorder, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("notion-connector: failed to create recording directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("notion-connector: failed to read recording directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("notion-connector: recording directory %s isn't empty", dir)
+	}
+
+	return &Recorder{
+		base:    base,
+		dir:     dir,
+		secrets: secrets,
+	}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	// Redacting can change the length of the body.
+	header.Del("Content-Length")
+
+	rec := Recording{
+		Method:       req.Method,
+		URL:          redactURL(req.URL, r.secrets),
+		RequestBody:  redact(string(reqBody), r.secrets),
+		StatusCode:   resp.StatusCode,
+		Header:       header,
+		ResponseBody: redact(string(respBody), r.secrets),
+	}
+	if err := r.write(&rec); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *Recorder) write(rec *Recording) error {
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.n++
+	name := filepath.Join(r.dir, fmt.Sprintf("%06d.json", r.n))
+	r.mu.Unlock()
+
+	if err := os.WriteFile(name, b, 0o600); err != nil {
+		return fmt.Errorf("notion-connector: failed to write recording: %w", err)
+	}
+
+	return nil
+}
+
+// Replayer is a transport that answers requests from the recordings in a
+// directory written by a Recorder, without sending them. Recordings of the
+// same request are replayed in the order they were recorded, and the last one
+// is repeated once they run out.
+type Replayer struct {
+	mu         sync.Mutex
+	recordings map[string][]*Recording
+}
+
+// NewReplayer loads the recordings in dir.
+func NewReplayer(dir string) (*Replayer, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("notion-connector: no recordings found in %s", dir)
+	}
+	sort.Strings(names)
+
+	r := &Replayer{
+		recordings: make(map[string][]*Recording),
+	}
+	for _, name := range names {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("notion-connector: failed to read recording: %w", err)
+		}
+
+		rec := &Recording{}
+		if err := json.Unmarshal(b, rec); err != nil {
+			return nil, fmt.Errorf("notion-connector: invalid recording %s: %w", name, err)
+		}
+		r.recordings[rec.key()] = append(r.recordings[rec.key()], rec)
+	}
+
+	return r, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	reqURL := redactURL(req.URL, nil)
+	key := recordingKey(req.Method, reqURL, redact(string(reqBody), nil))
+
+	r.mu.Lock()
+	recs := r.recordings[key]
+	if len(recs) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("notion-connector: no recording of %s %s", req.Method, reqURL)
+	}
+	rec := recs[0]
+	if len(recs) > 1 {
+		r.recordings[key] = recs[1:]
+	}
+	r.mu.Unlock()
+
+	header := rec.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.StatusCode, http.StatusText(rec.StatusCode)),
+		StatusCode:    rec.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(rec.ResponseBody)),
+		ContentLength: int64(len(rec.ResponseBody)),
+		Request:       req,
+	}, nil
+}
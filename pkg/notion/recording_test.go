@@ -0,0 +1,141 @@
+package notion_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-notion/pkg/notion"
+	"github.com/conductorone/baton-notion/pkg/notion/notiontest"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	s := notiontest.NewServer()
+	defer s.Close()
+	s.AddSCIMUsers(
+		notion.User{ID: "user-alice", UserName: "alice@example.com", Emails: []notion.Email{{Value: "alice@example.com", Primary: true}}, Active: true},
+		notion.User{ID: "user-bob", UserName: "bob@example.com", Emails: []notion.Email{{Value: "bob@example.com", Primary: true}}, Active: true},
+	)
+
+	dir := t.TempDir()
+	recorder, err := notion.NewRecorder(dir, s.Client().Transport, "scim-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := notion.NewScimClient("scim-token", &http.Client{Transport: recorder})
+
+	if _, err := client.GetUsers(ctx, 10, 1, notion.Filter{}); err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := client.FindUserByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := notion.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := notion.NewScimClient("other-token", &http.Client{Transport: replayer})
+
+	users, err := replay.GetUsers(ctx, 10, 1, notion.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users.Resources) != 2 {
+		t.Fatalf("got %d users, want 2", len(users.Resources))
+	}
+
+	// The replayed users carry redacted addresses, which requests made on
+	// replay use. They match the recordings of the original addresses.
+	email := users.Resources[0].PrimaryEmail()
+	if !strings.HasPrefix(email, "redacted-") || !strings.HasSuffix(email, "@example.com") {
+		t.Fatalf("got email %q, want a redacted address at example.com", email)
+	}
+	replayed, err := replay.FindUserByEmail(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.ID != recorded.ID || replayed.PrimaryEmail() != email {
+		t.Fatalf("got user %s <%s>, want %s <%s>", replayed.ID, replayed.PrimaryEmail(), recorded.ID, email)
+	}
+
+	if _, err := replay.GetUser(ctx, "user-carol"); err == nil {
+		t.Fatal("got no error replaying a request that wasn't recorded")
+	}
+}
+
+func TestRecorderRedacts(t *testing.T) {
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"token": "secret-token", "email": "Alice.Smith@example.com", "filter": "%22bob%40example.org%22"}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Set-Cookie": {"session=secret-token"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+
+	dir := t.TempDir()
+	recorder, err := notion.NewRecorder(dir, base, "secret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.notion.com/v1/users?email=alice.smith%40example.com&key=secret-token", strings.NewReader(`{"email": "bob@example.org"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+	resp, err := recorder.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Fatalf("got %d recordings, want 1", len(names))
+	}
+	b, err := os.ReadFile(names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recording := strings.ToLower(string(b))
+	for _, leaked := range []string{"secret-token", "bearer", "alice", "smith", "bob"} {
+		if strings.Contains(recording, leaked) {
+			t.Errorf("recording contains %q:\n%s", leaked, b)
+		}
+	}
+	for _, kept := range []string{"@example.com", "%40example.com", "@example.org", "%40example.org"} {
+		if !strings.Contains(recording, kept) {
+			t.Errorf("recording lacks domain %q:\n%s", kept, b)
+		}
+	}
+}
+
+func TestNewRecorderRefusesNonEmptyDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "000001.json"), []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := notion.NewRecorder(dir, nil); err == nil {
+		t.Fatal("got no error recording to a directory that isn't empty")
+	}
+}